@@ -9,19 +9,12 @@ import (
 	"github.com/FireGM/chats"
 	"github.com/FireGM/chats/goodgame"
 	"github.com/FireGM/chats/interfaces"
+	"github.com/FireGM/chats/overlay"
 	"github.com/FireGM/chats/peka2tv"
 	"github.com/FireGM/chats/twitch"
 	"github.com/FireGM/chats/youtube"
-
-	"github.com/gorilla/websocket"
 )
 
-// func makeHandle(ch chan interfaces.Message) func(interfaces.Message, interfaces.Bot) {
-// 	return func(message interfaces.Message, b interfaces.Bot) {
-// 		ch <- message
-// 	}
-// }
-
 type Conf struct {
 	TwitchToken    string `json:"twitch_token"`
 	TwitchClientID string `json:"twitch_client_id"`
@@ -29,29 +22,25 @@ type Conf struct {
 	YoutubeApiKey  string `json:"youtube_api_key"`
 }
 
-var upgrader = websocket.Upgrader{}
-
-func makeWSHandler(ch <-chan interfaces.Message) func(http.ResponseWriter, *http.Request) {
-	return func(w http.ResponseWriter, r *http.Request) {
-		c, err := upgrader.Upgrade(w, r, nil)
-		if err != nil {
-			log.Print("upgrade:", err)
-			return
-		}
-		defer c.Close()
-		for m := range ch {
-			c.WriteMessage(websocket.TextMessage, []byte(m.GetRenderFullHTML()))
-		}
-	}
-}
-
+// open http://localhost:8080/?theme=dark&platform=twitch,goodgame
+// or stream http://localhost:8080/events?format=html
 func main() {
 	ch := make(chan interfaces.Message)
 	conf := getConf()
+	ov := overlay.New(overlay.Options{})
+	sse := overlay.NewSSE(overlay.SSEOptions{})
+	go func() {
+		for m := range ch {
+			ov.Handle(m, nil)
+			sse.Handle(m, nil)
+		}
+	}()
 	connectToChats(ch, conf)
-	http.HandleFunc("/echo", makeWSHandler(ch))
-	http.Handle("/", http.FileServer(http.Dir(".")))
-	err := http.ListenAndServe(":8080", nil)
+	mux := http.NewServeMux()
+	mux.Handle("/events", sse)
+	mux.Handle("/", ov)
+	log.Println("overlay on http://localhost:8080/")
+	err := http.ListenAndServe(":8080", mux)
 	if err != nil {
 		panic("ListenAndServe: " + err.Error())
 	}
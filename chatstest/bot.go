@@ -0,0 +1,129 @@
+// Package chatstest provides fakes and message builders for testing code
+// built on top of interfaces.Bot and interfaces.Message.
+package chatstest
+
+import (
+	"sync"
+
+	"github.com/FireGM/chats/interfaces"
+)
+
+// Call is one recorded call of fake bot
+type Call struct {
+	Method   string
+	Channel  string
+	User     string
+	Text     string
+	Duration int
+}
+
+// NewBot creates fake bot, handler receives messages passed to Inject
+func NewBot(handleFunc func(interfaces.Message, interfaces.Bot)) *Bot {
+	return &Bot{handleFunc: handleFunc, channels: map[string]bool{}}
+}
+
+// Bot records all calls and never touches network.
+// Err, if set, returned from every call
+type Bot struct {
+	Err        error
+	handleFunc func(interfaces.Message, interfaces.Bot)
+	calls      []Call
+	channels   map[string]bool
+	locker     sync.RWMutex
+}
+
+// Inject passes message to handler synchronously
+func (b *Bot) Inject(messages ...interfaces.Message) {
+	for _, m := range messages {
+		if b.handleFunc != nil {
+			b.handleFunc(m, b)
+		}
+	}
+}
+
+func (b *Bot) record(c Call) error {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	b.calls = append(b.calls, c)
+	return b.Err
+}
+
+// Calls returns copy of all recorded calls
+func (b *Bot) Calls() []Call {
+	b.locker.RLock()
+	defer b.locker.RUnlock()
+	return append([]Call(nil), b.calls...)
+}
+
+// CallsOf returns recorded calls of method, like "SendMessageToChan"
+func (b *Bot) CallsOf(method string) []Call {
+	var res []Call
+	for _, c := range b.Calls() {
+		if c.Method == method {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// Sent returns texts sent to channel
+func (b *Bot) Sent(channel string) []string {
+	var res []string
+	for _, c := range b.CallsOf("SendMessageToChan") {
+		if c.Channel == channel {
+			res = append(res, c.Text)
+		}
+	}
+	return res
+}
+
+// Joined reports if channel was joined and not left
+func (b *Bot) Joined(channel string) bool {
+	b.locker.RLock()
+	defer b.locker.RUnlock()
+	return b.channels[channel]
+}
+
+// Reset forgets calls and channels
+func (b *Bot) Reset() {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	b.calls = nil
+	b.channels = map[string]bool{}
+}
+
+func (b *Bot) Disconnect() error {
+	return b.record(Call{Method: "Disconnect"})
+}
+
+func (b *Bot) Join(ch string) error {
+	err := b.record(Call{Method: "Join", Channel: ch})
+	if err == nil {
+		b.locker.Lock()
+		b.channels[ch] = true
+		b.locker.Unlock()
+	}
+	return err
+}
+
+func (b *Bot) Leave(ch string) error {
+	err := b.record(Call{Method: "Leave", Channel: ch})
+	if err == nil {
+		b.locker.Lock()
+		delete(b.channels, ch)
+		b.locker.Unlock()
+	}
+	return err
+}
+
+func (b *Bot) SendMessageToChan(ch, message string) error {
+	return b.record(Call{Method: "SendMessageToChan", Channel: ch, Text: message})
+}
+
+func (b *Bot) Ban(channel, nickname string) error {
+	return b.record(Call{Method: "Ban", Channel: channel, User: nickname})
+}
+
+func (b *Bot) Timeout(channel, nickname string, t int) error {
+	return b.record(Call{Method: "Timeout", Channel: channel, User: nickname, Duration: t})
+}
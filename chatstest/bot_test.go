@@ -0,0 +1,90 @@
+package chatstest
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/FireGM/chats/goodgame"
+	"github.com/FireGM/chats/interfaces"
+)
+
+func TestBotRecords(t *testing.T) {
+	var handled []string
+	b := NewBot(func(m interfaces.Message, bot interfaces.Bot) {
+		handled = append(handled, m.GetTextMessage())
+		bot.SendMessageToChan(m.GetChannelName(), "re: "+m.GetTextMessage())
+	})
+	b.Join("a")
+	b.Join("b")
+	b.Leave("b")
+	b.Inject(Twitch().From("u").Channel("a").Text("hi").Build())
+	b.Ban("a", "spammer")
+	b.Timeout("a", "loud", 600)
+
+	if !reflect.DeepEqual(handled, []string{"hi"}) {
+		t.Errorf("handled = %v, want %v", handled, []string{"hi"})
+	}
+	if !b.Joined("a") || b.Joined("b") {
+		t.Errorf("Joined(a), Joined(b) = %v, %v, want true, false", b.Joined("a"), b.Joined("b"))
+	}
+	if got := b.Sent("a"); !reflect.DeepEqual(got, []string{"re: hi"}) {
+		t.Errorf("Sent(a) = %v, want %v", got, []string{"re: hi"})
+	}
+	want := []Call{
+		{Method: "Join", Channel: "a"},
+		{Method: "Join", Channel: "b"},
+		{Method: "Leave", Channel: "b"},
+		{Method: "SendMessageToChan", Channel: "a", Text: "re: hi"},
+		{Method: "Ban", Channel: "a", User: "spammer"},
+		{Method: "Timeout", Channel: "a", User: "loud", Duration: 600},
+	}
+	if got := b.Calls(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Calls() = %+v, want %+v", got, want)
+	}
+	if got := b.CallsOf("Ban"); len(got) != 1 || got[0].User != "spammer" {
+		t.Errorf("CallsOf(Ban) = %+v", got)
+	}
+
+	b.Reset()
+	if len(b.Calls()) != 0 || b.Joined("a") {
+		t.Errorf("Reset() keeps calls %v or channels", b.Calls())
+	}
+}
+
+func TestBotErr(t *testing.T) {
+	b := NewBot(nil)
+	b.Err = errors.New("down")
+	if err := b.Join("a"); err != b.Err {
+		t.Errorf("Join() = %v, want %v", err, b.Err)
+	}
+	if b.Joined("a") {
+		t.Error("failed Join marks channel joined")
+	}
+	if err := b.SendMessageToChan("a", "hi"); err != b.Err {
+		t.Errorf("SendMessageToChan() = %v, want %v", err, b.Err)
+	}
+	// failed calls are recorded too
+	if got := len(b.Calls()); got != 2 {
+		t.Errorf("len(Calls()) = %v, want %v", got, 2)
+	}
+}
+
+func TestEmoteRegistrationParallel(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m := GoodGame().From(1, "u").Text(":kappa:").
+				Emote(goodgame.Smile{Name: "kappa", ImgBig: "big.png"}).Build()
+			if got := m.GetRenderMessHTML(); got == "" {
+				t.Error("empty render")
+			}
+			Peka2tv().From(1, "u").Text(":peka:").Emote("peka", "peka.png", 0).Icon(1, "icon.png").
+				Build().GetRenderFullHTML()
+		}()
+	}
+	wg.Wait()
+}
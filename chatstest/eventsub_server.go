@@ -0,0 +1,165 @@
+package chatstest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// NewEventSubServer starts fake of twitch eventsub websocket.
+// Set twitch.EventSubURL = s.URL() to use it
+func NewEventSubServer() *EventSubServer {
+	s := &EventSubServer{Keepalive: 10, clients: map[*esClient]bool{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", s.handle)
+	s.server = httptest.NewServer(mux)
+	return s
+}
+
+// EventSubServer sends welcome with new session to every connection,
+// connection by reconnect url keeps session of old one
+type EventSubServer struct {
+	// Keepalive is keepalive_timeout_seconds of sessions
+	Keepalive int
+
+	server   *httptest.Server
+	upgrader websocket.Upgrader
+	clients  map[*esClient]bool
+	sessions int
+	messages int
+	locker   sync.RWMutex
+}
+
+type esClient struct {
+	conn    *websocket.Conn
+	session string
+	locker  sync.Mutex
+}
+
+func (c *esClient) send(messageType, messageID string, payload interface{}) error {
+	b, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]string{"message_id": messageID, "message_type": messageType,
+			"message_timestamp": time.Now().UTC().Format(time.RFC3339Nano)},
+		"payload": payload,
+	})
+	if err != nil {
+		return err
+	}
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	return c.conn.WriteMessage(websocket.TextMessage, b)
+}
+
+// URL is websocket url of eventsub
+func (s *EventSubServer) URL() string {
+	return "ws" + strings.TrimPrefix(s.server.URL, "http") + "/ws"
+}
+
+func (s *EventSubServer) Close() {
+	s.DisconnectAll()
+	s.server.Close()
+}
+
+// Sessions returns ids of sessions of connected clients
+func (s *EventSubServer) Sessions() []string {
+	var sessions []string
+	for _, c := range s.clientList() {
+		sessions = append(sessions, c.session)
+	}
+	return sessions
+}
+
+// Notify sends notification to every client, it returns message id
+func (s *EventSubServer) Notify(subType string, event interface{}) string {
+	s.locker.Lock()
+	s.messages++
+	id := "message-" + strconv.Itoa(s.messages)
+	s.locker.Unlock()
+	s.NotifyWithID(id, subType, event)
+	return id
+}
+
+// NotifyWithID sends notification with given message id, it is used to send duplicates
+func (s *EventSubServer) NotifyWithID(messageID, subType string, event interface{}) {
+	payload := map[string]interface{}{
+		"subscription": map[string]string{"id": subType + "-sub", "type": subType, "status": "enabled"},
+		"event":        event,
+	}
+	for _, c := range s.clientList() {
+		c.send("notification", messageID, payload)
+	}
+}
+
+// Reconnect sends session_reconnect to every client
+func (s *EventSubServer) Reconnect() {
+	for _, c := range s.clientList() {
+		c.send("session_reconnect", "", map[string]interface{}{"session": map[string]interface{}{
+			"id": c.session, "status": "reconnecting", "reconnect_url": s.URL() + "?session=" + c.session}})
+	}
+}
+
+// DisconnectAll drops all client connections
+func (s *EventSubServer) DisconnectAll() {
+	for _, c := range s.clientList() {
+		c.conn.Close()
+	}
+}
+
+func (s *EventSubServer) clientList() []*esClient {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	var clients []*esClient
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	return clients
+}
+
+func (s *EventSubServer) handle(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &esClient{conn: conn, session: r.URL.Query().Get("session")}
+	s.locker.Lock()
+	if c.session == "" {
+		s.sessions++
+		c.session = "session-" + strconv.Itoa(s.sessions)
+	}
+	s.clients[c] = true
+	keepalive := s.Keepalive
+	s.locker.Unlock()
+	defer func() {
+		s.locker.Lock()
+		delete(s.clients, c)
+		s.locker.Unlock()
+		conn.Close()
+	}()
+	c.send("session_welcome", "", map[string]interface{}{"session": map[string]interface{}{
+		"id": c.session, "status": "connected", "keepalive_timeout_seconds": keepalive}})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(time.Duration(keepalive) * time.Second / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.send("session_keepalive", "", map[string]interface{}{})
+			case <-done:
+				return
+			}
+		}
+	}()
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
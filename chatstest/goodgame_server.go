@@ -0,0 +1,226 @@
+package chatstest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// GoodGameFrame is json frame of goodgame chat protocol
+type GoodGameFrame struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// NewGoodGameServer starts fake goodgame websocket chat.
+// Use it with goodgame.Bot.SetURL(s.URL())
+func NewGoodGameServer() *GoodGameServer {
+	s := &GoodGameServer{clients: map[*ggClient]bool{}, scripts: map[string][]GoodGameFrame{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/chat/websocket", s.handle)
+	s.server = httptest.NewServer(mux)
+	return s
+}
+
+// GoodGameServer handles auth, join, unjoin, send_message and ban frames
+type GoodGameServer struct {
+	// Token, if set, is only accepted chat token
+	Token string
+	// RejectAuth makes server answer every auth with error
+	RejectAuth bool
+
+	server   *httptest.Server
+	upgrader websocket.Upgrader
+	clients  map[*ggClient]bool
+	scripts  map[string][]GoodGameFrame
+	received []GoodGameFrame
+	locker   sync.RWMutex
+}
+
+type ggClient struct {
+	conn     *websocket.Conn
+	userID   int
+	username string
+	channels map[string]bool
+	locker   sync.Mutex
+}
+
+func (c *ggClient) write(messageType int, data []byte) error {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	return c.conn.WriteMessage(messageType, data)
+}
+
+func (c *ggClient) send(t string, data interface{}) error {
+	b, err := json.Marshal(struct {
+		Type string      `json:"type"`
+		Data interface{} `json:"data"`
+	}{t, data})
+	if err != nil {
+		return err
+	}
+	return c.write(websocket.TextMessage, b)
+}
+
+func (c *ggClient) joined(ch string) bool {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	return c.channels[ch]
+}
+
+// URL is websocket url of chat
+func (s *GoodGameServer) URL() string {
+	return "ws" + strings.TrimPrefix(s.server.URL, "http") + "/chat/websocket"
+}
+
+func (s *GoodGameServer) Close() {
+	s.DisconnectAll()
+	s.server.Close()
+}
+
+// Script sets frames sent to client right after it joins channel
+func (s *GoodGameServer) Script(channelID string, frames ...GoodGameFrame) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.scripts[channelID] = append(s.scripts[channelID], frames...)
+}
+
+// Frame makes frame with data marshaled to json
+func Frame(t string, data interface{}) GoodGameFrame {
+	b, _ := json.Marshal(data)
+	return GoodGameFrame{Type: t, Data: b}
+}
+
+// Broadcast sends frame to every client
+func (s *GoodGameServer) Broadcast(f GoodGameFrame) {
+	for _, c := range s.clientList() {
+		c.send(f.Type, f.Data)
+	}
+}
+
+// Message sends chat message to clients joined to channel
+func (s *GoodGameServer) Message(channelID string, userID int, username, text string) {
+	id, _ := strconv.Atoi(channelID)
+	f := Frame("message", map[string]interface{}{"channel_id": id, "user_id": userID,
+		"user_name": username, "text": text, "timestamp": 0})
+	for _, c := range s.clientList() {
+		if c.joined(channelID) {
+			c.send(f.Type, f.Data)
+		}
+	}
+}
+
+// InjectMalformed sends broken frames to every client
+func (s *GoodGameServer) InjectMalformed() {
+	for _, c := range s.clientList() {
+		c.write(websocket.TextMessage, []byte(`{"type":"message","data":`))
+		c.write(websocket.TextMessage, []byte(`not json`))
+		c.write(websocket.TextMessage, []byte(`{"type":"message","data":{"channel_id":[]}}`))
+	}
+}
+
+// DisconnectAll drops all client connections
+func (s *GoodGameServer) DisconnectAll() {
+	for _, c := range s.clientList() {
+		c.conn.Close()
+	}
+}
+
+// Connections is count of connected clients
+func (s *GoodGameServer) Connections() int {
+	return len(s.clientList())
+}
+
+// Received returns all frames got from clients
+func (s *GoodGameServer) Received() []GoodGameFrame {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	return append([]GoodGameFrame(nil), s.received...)
+}
+
+func (s *GoodGameServer) clientList() []*ggClient {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	var res []*ggClient
+	for c := range s.clients {
+		res = append(res, c)
+	}
+	return res
+}
+
+func (s *GoodGameServer) handle(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &ggClient{conn: conn, channels: map[string]bool{}}
+	s.locker.Lock()
+	s.clients[c] = true
+	s.locker.Unlock()
+	defer func() {
+		conn.Close()
+		s.locker.Lock()
+		delete(s.clients, c)
+		s.locker.Unlock()
+	}()
+	c.send("welcome", map[string]interface{}{"protocolVersion": 1.1, "serverIdent": "fake"})
+	for {
+		var f GoodGameFrame
+		if err := conn.ReadJSON(&f); err != nil {
+			return
+		}
+		s.locker.Lock()
+		s.received = append(s.received, f)
+		s.locker.Unlock()
+		s.serve(c, f)
+	}
+}
+
+func (s *GoodGameServer) serve(c *ggClient, f GoodGameFrame) {
+	var data struct {
+		UserID    int    `json:"user_id"`
+		Token     string `json:"token"`
+		ChannelID string `json:"channel_id"`
+		Text      string `json:"text"`
+	}
+	json.Unmarshal(f.Data, &data)
+	switch f.Type {
+	case "auth":
+		if s.RejectAuth || (s.Token != "" && data.Token != s.Token) {
+			c.send("error", map[string]interface{}{"channel_id": nil, "error_num": 0, "errorMsg": "auth failed"})
+			return
+		}
+		c.userID = data.UserID
+		c.username = fmt.Sprintf("user%d", data.UserID)
+		c.send("success_auth", map[string]interface{}{"user_id": data.UserID, "user_name": c.username})
+	case "join":
+		c.locker.Lock()
+		c.channels[data.ChannelID] = true
+		c.locker.Unlock()
+		c.send("success_join", map[string]interface{}{"channel_id": data.ChannelID})
+		s.locker.RLock()
+		script := append([]GoodGameFrame(nil), s.scripts[data.ChannelID]...)
+		s.locker.RUnlock()
+		for _, sf := range script {
+			c.send(sf.Type, sf.Data)
+		}
+	case "unjoin":
+		c.locker.Lock()
+		delete(c.channels, data.ChannelID)
+		c.locker.Unlock()
+		c.send("success_unjoin", map[string]interface{}{"channel_id": data.ChannelID})
+	case "send_message":
+		s.Message(data.ChannelID, c.userID, c.username, data.Text)
+	case "ban":
+		var ban map[string]interface{}
+		json.Unmarshal(f.Data, &ban)
+		ban["channel_id"], _ = strconv.Atoi(data.ChannelID)
+		s.Broadcast(Frame("user_ban", ban))
+	}
+}
@@ -0,0 +1,429 @@
+package chatstest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/FireGM/chats/twitch"
+)
+
+// NewHelixServer starts fake of twitch helix api.
+// Set twitch.HelixURL = s.URL() to use it
+func NewHelixServer() *HelixServer {
+	s := &HelixServer{PageSize: 20, users: map[string]twitch.HelixUser{},
+		badges: map[string][]twitch.HelixBadgeSet{}, settings: map[string]twitch.ChatSettings{},
+		bans: map[string]map[string]int{}, subs: map[string]EventSubSubscription{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users", s.usersHandler)
+	mux.HandleFunc("/chat/badges/global", s.badgesHandler)
+	mux.HandleFunc("/chat/badges", s.badgesHandler)
+	mux.HandleFunc("/chat/settings", s.settingsHandler)
+	mux.HandleFunc("/moderation/bans", s.bansHandler)
+	mux.HandleFunc("/moderation/banned", s.bannedHandler)
+	mux.HandleFunc("/moderation/chat", s.chatHandler)
+	mux.HandleFunc("/eventsub/subscriptions", s.eventSubHandler)
+	mux.HandleFunc("/whispers", s.whispersHandler)
+	s.server = httptest.NewServer(s.auth(mux))
+	return s
+}
+
+// HelixRequest is request received by server
+type HelixRequest struct {
+	Method string
+	Path   string
+	Query  string
+	Body   string
+}
+
+// HelixWhisper is whisper sent by client
+type HelixWhisper struct {
+	FromUserID string
+	ToUserID   string
+	Message    string
+}
+
+// EventSubSubscription is subscription created by client
+type EventSubSubscription struct {
+	ID        string
+	Type      string
+	Version   string
+	Condition map[string]string
+	SessionID string
+}
+
+// HelixServer serves users, chat badges, chat settings, moderation, eventsub and whispers endpoints
+type HelixServer struct {
+	// ClientID and Token, if set, are required in requests
+	ClientID string
+	Token    string
+	// PageSize of paginated lists
+	PageSize int
+
+	server   *httptest.Server
+	users    map[string]twitch.HelixUser
+	badges   map[string][]twitch.HelixBadgeSet
+	settings map[string]twitch.ChatSettings
+	bans     map[string]map[string]int
+	deleted  []string
+	whispers []HelixWhisper
+	subs     map[string]EventSubSubscription
+	lastSub  int
+	received []HelixRequest
+	limited  int
+	locker   sync.RWMutex
+}
+
+// URL of api, use as twitch.HelixURL
+func (s *HelixServer) URL() string {
+	return s.server.URL
+}
+
+func (s *HelixServer) Close() {
+	s.server.Close()
+}
+
+// AddUser adds user which can be found by id and login
+func (s *HelixServer) AddUser(id, login string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.users[login] = twitch.HelixUser{ID: id, Login: login, DisplayName: login}
+}
+
+// SetBadges sets badges of channel, empty broadcasterID is global badges
+func (s *HelixServer) SetBadges(broadcasterID string, sets ...twitch.HelixBadgeSet) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.badges[broadcasterID] = sets
+}
+
+// Bans returns banned users of channel with duration, 0 is permanent ban
+func (s *HelixServer) Bans(broadcasterID string) map[string]int {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	bans := map[string]int{}
+	for user, d := range s.bans[broadcasterID] {
+		bans[user] = d
+	}
+	return bans
+}
+
+// Deleted returns ids of deleted messages, empty id is clear of chat
+func (s *HelixServer) Deleted() []string {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	return append([]string(nil), s.deleted...)
+}
+
+// Whispers returns whispers sent by clients
+func (s *HelixServer) Whispers() []HelixWhisper {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	return append([]HelixWhisper(nil), s.whispers...)
+}
+
+// Settings returns chat settings of channel
+func (s *HelixServer) Settings(broadcasterID string) twitch.ChatSettings {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	return s.settings[broadcasterID]
+}
+
+// Subscriptions returns eventsub subscriptions sorted by id
+func (s *HelixServer) Subscriptions() []EventSubSubscription {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	var subs []EventSubSubscription
+	for _, sub := range s.subs {
+		subs = append(subs, sub)
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].ID < subs[j].ID })
+	return subs
+}
+
+// RateLimit makes next n requests answered with 429
+func (s *HelixServer) RateLimit(n int) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.limited = n
+}
+
+// Received returns all requests got from clients
+func (s *HelixServer) Received() []HelixRequest {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	return append([]HelixRequest(nil), s.received...)
+}
+
+func helixError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(twitch.HelixError{Status: code, Err: http.StatusText(code), Message: message})
+}
+
+func (s *HelixServer) auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		s.locker.Lock()
+		s.received = append(s.received, HelixRequest{Method: r.Method, Path: r.URL.Path,
+			Query: r.URL.RawQuery, Body: string(body)})
+		limited := s.limited > 0
+		if limited {
+			s.limited--
+		}
+		s.locker.Unlock()
+		w.Header().Set("Ratelimit-Limit", "800")
+		w.Header().Set("Ratelimit-Reset", strconv.FormatInt(time.Now().Unix(), 10))
+		if limited {
+			w.Header().Set("Ratelimit-Remaining", "0")
+			helixError(w, http.StatusTooManyRequests, "Too Many Requests")
+			return
+		}
+		w.Header().Set("Ratelimit-Remaining", "799")
+		if s.ClientID != "" && r.Header.Get("Client-Id") != s.ClientID {
+			helixError(w, http.StatusUnauthorized, "Client ID and OAuth token do not match")
+			return
+		}
+		if s.Token != "" && r.Header.Get("Authorization") != "Bearer "+s.Token {
+			helixError(w, http.StatusUnauthorized, "Invalid OAuth token")
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *HelixServer) usersHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if len(q["login"])+len(q["id"]) > 100 {
+		helixError(w, http.StatusBadRequest, "The maximum number of login and id values is 100")
+		return
+	}
+	var data []twitch.HelixUser
+	s.locker.RLock()
+	for _, login := range q["login"] {
+		if u, ok := s.users[login]; ok {
+			data = append(data, u)
+		}
+	}
+	for _, id := range q["id"] {
+		for _, u := range s.users {
+			if u.ID == id {
+				data = append(data, u)
+			}
+		}
+	}
+	s.locker.RUnlock()
+	writeJSON(w, map[string]interface{}{"data": data})
+}
+
+func (s *HelixServer) badgesHandler(w http.ResponseWriter, r *http.Request) {
+	s.locker.RLock()
+	data := s.badges[r.URL.Query().Get("broadcaster_id")]
+	s.locker.RUnlock()
+	if data == nil {
+		data = []twitch.HelixBadgeSet{}
+	}
+	writeJSON(w, map[string]interface{}{"data": data})
+}
+
+func (s *HelixServer) settingsHandler(w http.ResponseWriter, r *http.Request) {
+	broadcaster := r.URL.Query().Get("broadcaster_id")
+	if r.Method == "PATCH" {
+		var update twitch.ChatSettings
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			helixError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.locker.Lock()
+		cur := s.settings[broadcaster]
+		cur.BroadcasterID = broadcaster
+		if update.EmoteMode != nil {
+			cur.EmoteMode = update.EmoteMode
+		}
+		if update.FollowerMode != nil {
+			cur.FollowerMode = update.FollowerMode
+		}
+		if update.FollowerModeDuration != nil {
+			cur.FollowerModeDuration = update.FollowerModeDuration
+		}
+		if update.NonModeratorChatDelay != nil {
+			cur.NonModeratorChatDelay = update.NonModeratorChatDelay
+		}
+		if update.NonModeratorChatDelayDuration != nil {
+			cur.NonModeratorChatDelayDuration = update.NonModeratorChatDelayDuration
+		}
+		if update.SlowMode != nil {
+			cur.SlowMode = update.SlowMode
+		}
+		if update.SlowModeWaitTime != nil {
+			cur.SlowModeWaitTime = update.SlowModeWaitTime
+		}
+		if update.SubscriberMode != nil {
+			cur.SubscriberMode = update.SubscriberMode
+		}
+		if update.UniqueChatMode != nil {
+			cur.UniqueChatMode = update.UniqueChatMode
+		}
+		s.settings[broadcaster] = cur
+		s.locker.Unlock()
+		writeJSON(w, map[string]interface{}{"data": []twitch.ChatSettings{cur}})
+		return
+	}
+	s.locker.RLock()
+	cur := s.settings[broadcaster]
+	s.locker.RUnlock()
+	cur.BroadcasterID = broadcaster
+	writeJSON(w, map[string]interface{}{"data": []twitch.ChatSettings{cur}})
+}
+
+func (s *HelixServer) bansHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	broadcaster := q.Get("broadcaster_id")
+	if q.Get("moderator_id") == "" {
+		helixError(w, http.StatusBadRequest, "Missing required parameter \"moderator_id\"")
+		return
+	}
+	switch r.Method {
+	case "POST":
+		var req struct {
+			Data struct {
+				UserID   string `json:"user_id"`
+				Duration int    `json:"duration"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Data.UserID == "" {
+			helixError(w, http.StatusBadRequest, "Missing user_id")
+			return
+		}
+		s.locker.Lock()
+		if s.bans[broadcaster] == nil {
+			s.bans[broadcaster] = map[string]int{}
+		}
+		s.bans[broadcaster][req.Data.UserID] = req.Data.Duration
+		s.locker.Unlock()
+		writeJSON(w, map[string]interface{}{"data": []interface{}{req.Data}})
+	case "DELETE":
+		s.locker.Lock()
+		_, ok := s.bans[broadcaster][q.Get("user_id")]
+		delete(s.bans[broadcaster], q.Get("user_id"))
+		s.locker.Unlock()
+		if !ok {
+			helixError(w, http.StatusBadRequest, "The user specified in the user_id field is not banned.")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		helixError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// bannedHandler lists banned users by pages of PageSize
+func (s *HelixServer) bannedHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	s.locker.RLock()
+	var users []string
+	for user := range s.bans[q.Get("broadcaster_id")] {
+		users = append(users, user)
+	}
+	size := s.PageSize
+	s.locker.RUnlock()
+	sort.Strings(users)
+	start, _ := strconv.Atoi(q.Get("after"))
+	if start > len(users) {
+		start = len(users)
+	}
+	end := start + size
+	cursor := strconv.Itoa(end)
+	if end >= len(users) {
+		end = len(users)
+		cursor = ""
+	}
+	data := []map[string]string{}
+	for _, user := range users[start:end] {
+		data = append(data, map[string]string{"user_id": user})
+	}
+	writeJSON(w, map[string]interface{}{"data": data, "pagination": map[string]string{"cursor": cursor}})
+}
+
+func (s *HelixServer) chatHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		helixError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	s.locker.Lock()
+	s.deleted = append(s.deleted, r.URL.Query().Get("message_id"))
+	s.locker.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *HelixServer) whispersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		helixError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Message == "" {
+		helixError(w, http.StatusBadRequest, "missing message")
+		return
+	}
+	q := r.URL.Query()
+	s.locker.Lock()
+	s.whispers = append(s.whispers, HelixWhisper{FromUserID: q.Get("from_user_id"), ToUserID: q.Get("to_user_id"),
+		Message: body.Message})
+	s.locker.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *HelixServer) eventSubHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		var req struct {
+			twitch.EventSubCondition
+			Transport struct {
+				Method    string `json:"method"`
+				SessionID string `json:"session_id"`
+			} `json:"transport"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Type == "" {
+			helixError(w, http.StatusBadRequest, "invalid subscription")
+			return
+		}
+		if req.Transport.Method != "websocket" || req.Transport.SessionID == "" {
+			helixError(w, http.StatusBadRequest, "websocket transport requires session_id")
+			return
+		}
+		s.locker.Lock()
+		s.lastSub++
+		sub := EventSubSubscription{ID: fmt.Sprintf("sub-%03d", s.lastSub), Type: req.Type, Version: req.Version,
+			Condition: req.Condition, SessionID: req.Transport.SessionID}
+		s.subs[sub.ID] = sub
+		s.locker.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": []map[string]interface{}{{
+			"id": sub.ID, "status": "enabled", "type": sub.Type, "version": sub.Version,
+			"condition": sub.Condition}}})
+	case "DELETE":
+		s.locker.Lock()
+		_, ok := s.subs[r.URL.Query().Get("id")]
+		delete(s.subs, r.URL.Query().Get("id"))
+		s.locker.Unlock()
+		if !ok {
+			helixError(w, http.StatusNotFound, "subscription not found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		helixError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
@@ -0,0 +1,333 @@
+package chatstest
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/FireGM/chats/goodgame"
+	"github.com/FireGM/chats/peka2tv"
+	"github.com/FireGM/chats/twitch"
+	"github.com/FireGM/chats/youtube"
+)
+
+const (
+	privMsg  = "PRIVMSG"
+	clearMsg = "CLEARCHAT"
+)
+
+// TwitchBuilder builds twitch.Message step by step
+type TwitchBuilder struct {
+	m twitch.Message
+}
+
+// Twitch starts building of twitch PRIVMSG
+func Twitch() *TwitchBuilder {
+	return &TwitchBuilder{m: twitch.Message{Type: privMsg, Badges: map[string]string{},
+		Tags: map[string]string{}, Emotes: map[string]*twitch.Emote{}}}
+}
+
+func (t *TwitchBuilder) From(user string) *TwitchBuilder {
+	t.m.User = user
+	if t.m.DisplayName == "" {
+		t.m.DisplayName = user
+	}
+	return t
+}
+
+func (t *TwitchBuilder) DisplayName(name string) *TwitchBuilder {
+	t.m.DisplayName = name
+	return t
+}
+
+func (t *TwitchBuilder) Channel(ch string) *TwitchBuilder {
+	t.m.Channel = ch
+	return t
+}
+
+func (t *TwitchBuilder) RoomID(id int) *TwitchBuilder {
+	t.m.RoomID = id
+	return t
+}
+
+func (t *TwitchBuilder) Text(text string) *TwitchBuilder {
+	t.m.Text = text
+	return t
+}
+
+func (t *TwitchBuilder) Color(color string) *TwitchBuilder {
+	t.m.Color = color
+	return t
+}
+
+func (t *TwitchBuilder) ID(id string) *TwitchBuilder {
+	t.m.Tags["id"] = id
+	return t
+}
+
+func (t *TwitchBuilder) Tag(key, value string) *TwitchBuilder {
+	t.m.Tags[key] = value
+	return t
+}
+
+func (t *TwitchBuilder) Badge(name, version string) *TwitchBuilder {
+	t.m.Badges[name] = version
+	return t
+}
+
+func (t *TwitchBuilder) Moderator() *TwitchBuilder {
+	t.m.Mod = 1
+	return t.Badge("moderator", "1")
+}
+
+func (t *TwitchBuilder) Subscriber(version string) *TwitchBuilder {
+	t.m.Tags["subscriber"] = "1"
+	return t.Badge("subscriber", version)
+}
+
+// Bits sets amount of cheered bits, text should contain cheermotes like Cheer100
+func (t *TwitchBuilder) Bits(amount int) *TwitchBuilder {
+	t.m.Bits = amount
+	return t
+}
+
+// Emote marks every word of text equal to name as emote with id, call it after Text
+func (t *TwitchBuilder) Emote(id, name string) *TwitchBuilder {
+	var positions []twitch.EmotePosition
+	start := 0
+	for _, word := range strings.Split(t.m.Text, " ") {
+		length := utf8.RuneCountInString(word)
+		if word == name {
+			positions = append(positions, twitch.EmotePosition{Start: start, End: start + length - 1})
+		}
+		start += length + 1
+	}
+	t.m.Emotes[name] = &twitch.Emote{Type: "twitch", ID: id, Name: name, Count: len(positions),
+		Positions: positions}
+	return t
+}
+
+// ReplyTo makes message reply to parent message with id
+func (t *TwitchBuilder) ReplyTo(id, login, body string) *TwitchBuilder {
+	t.m.Reply = &twitch.ReplyParent{MsgID: id, UserLogin: login, DisplayName: login, Body: body}
+	return t
+}
+
+// Clear makes CLEARCHAT for user
+func (t *TwitchBuilder) Clear() *TwitchBuilder {
+	t.m.Type = clearMsg
+	t.m.Text = t.m.User
+	return t
+}
+
+// Build returns copy, changes of it or of builder don't touch other built messages
+func (t *TwitchBuilder) Build() *twitch.Message {
+	m := t.m
+	m.Badges = copyMap(t.m.Badges)
+	m.Tags = copyMap(t.m.Tags)
+	m.Emotes = make(map[string]*twitch.Emote, len(t.m.Emotes))
+	for name, e := range t.m.Emotes {
+		c := *e
+		c.Positions = append([]twitch.EmotePosition(nil), e.Positions...)
+		m.Emotes[name] = &c
+	}
+	if t.m.Reply != nil {
+		r := *t.m.Reply
+		m.Reply = &r
+	}
+	return &m
+}
+
+func copyMap(src map[string]string) map[string]string {
+	dst := make(map[string]string, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+// GoodGameBuilder builds goodgame.Message step by step
+type GoodGameBuilder struct {
+	m goodgame.Message
+}
+
+// GoodGame starts building of goodgame chat message
+func GoodGame() *GoodGameBuilder {
+	return &GoodGameBuilder{m: goodgame.Message{Type: privMsg}}
+}
+
+func (g *GoodGameBuilder) From(userID int, username string) *GoodGameBuilder {
+	g.m.UserID = userID
+	g.m.Username = username
+	return g
+}
+
+func (g *GoodGameBuilder) Channel(id int) *GoodGameBuilder {
+	g.m.Channel = id
+	return g
+}
+
+func (g *GoodGameBuilder) Text(text string) *GoodGameBuilder {
+	g.m.Text = text
+	return g
+}
+
+func (g *GoodGameBuilder) Color(color string) *GoodGameBuilder {
+	g.m.Color = color
+	return g
+}
+
+func (g *GoodGameBuilder) Moderator() *GoodGameBuilder {
+	g.m.UserRights = 10
+	return g
+}
+
+// Premium makes user subscriber of channels
+func (g *GoodGameBuilder) Premium(channels ...int) *GoodGameBuilder {
+	g.m.Premium = true
+	g.m.Premiums = append(g.m.Premiums, channels...)
+	return g
+}
+
+func (g *GoodGameBuilder) Donat(level int) *GoodGameBuilder {
+	g.m.Donat = level
+	return g
+}
+
+// Emote registers smile in goodgame smiles, text should contain :name:.
+// Registration is global and visible to all messages, so use unique names in
+// parallel tests
+func (g *GoodGameBuilder) Emote(s goodgame.Smile) *GoodGameBuilder {
+	goodgame.AddSmile(s)
+	return g
+}
+
+// Clear makes ban message
+func (g *GoodGameBuilder) Clear() *GoodGameBuilder {
+	g.m.Type = clearMsg
+	return g
+}
+
+func (g *GoodGameBuilder) Build() *goodgame.Message {
+	m := g.m
+	m.Premiums = append([]int(nil), g.m.Premiums...)
+	return &m
+}
+
+// Peka2tvBuilder builds peka2tv.Message step by step
+type Peka2tvBuilder struct {
+	m peka2tv.Message
+}
+
+// Peka2tv starts building of peka2tv chat message
+func Peka2tv() *Peka2tvBuilder {
+	return &Peka2tvBuilder{m: peka2tv.Message{Type: privMsg}}
+}
+
+func (p *Peka2tvBuilder) ID(id int) *Peka2tvBuilder {
+	p.m.ID = id
+	return p
+}
+
+func (p *Peka2tvBuilder) From(userID int, name string) *Peka2tvBuilder {
+	p.m.From = peka2tv.User{ID: userID, Name: name}
+	return p
+}
+
+func (p *Peka2tvBuilder) To(userID int, name string) *Peka2tvBuilder {
+	p.m.To = peka2tv.User{ID: userID, Name: name}
+	return p
+}
+
+func (p *Peka2tvBuilder) Channel(ch string) *Peka2tvBuilder {
+	p.m.Channel = ch
+	return p
+}
+
+func (p *Peka2tvBuilder) Text(text string) *Peka2tvBuilder {
+	p.m.Text = text
+	return p
+}
+
+// Icon sets badge icon of user and registers it url globally like Emote
+func (p *Peka2tvBuilder) Icon(id int, url string) *Peka2tvBuilder {
+	p.m.Store.Icon = id
+	peka2tv.AddIcon(peka2tv.Icon{ID: id, URL: url})
+	return p
+}
+
+func (p *Peka2tvBuilder) Bonuses(ids ...int) *Peka2tvBuilder {
+	p.m.Store.Bonuses = append(p.m.Store.Bonuses, ids...)
+	return p
+}
+
+// Emote registers smile with code, text should contain :code:.
+// Registration is global and visible to all messages, so use unique codes in
+// parallel tests
+func (p *Peka2tvBuilder) Emote(code, url string, bonusID int) *Peka2tvBuilder {
+	peka2tv.AddSmile(code, url, bonusID)
+	return p
+}
+
+// Clear makes remove message
+func (p *Peka2tvBuilder) Clear() *Peka2tvBuilder {
+	p.m.Type = clearMsg
+	return p
+}
+
+func (p *Peka2tvBuilder) Build() *peka2tv.Message {
+	m := p.m
+	m.Store.Bonuses = append([]int(nil), p.m.Store.Bonuses...)
+	return &m
+}
+
+// YoutubeBuilder builds youtube.Message step by step
+type YoutubeBuilder struct {
+	m youtube.Message
+}
+
+// Youtube starts building of youtube chat message
+func Youtube() *YoutubeBuilder {
+	return &YoutubeBuilder{m: youtube.Message{Type: privMsg, SendTime: time.Now()}}
+}
+
+func (y *YoutubeBuilder) From(channelID, name string) *YoutubeBuilder {
+	y.m.OwnerUID = channelID
+	y.m.Owner = name
+	return y
+}
+
+func (y *YoutubeBuilder) Channel(channelID string) *YoutubeBuilder {
+	y.m.ChannelID = channelID
+	return y
+}
+
+func (y *YoutubeBuilder) Text(text string) *YoutubeBuilder {
+	y.m.Text = text
+	return y
+}
+
+func (y *YoutubeBuilder) Time(t time.Time) *YoutubeBuilder {
+	y.m.SendTime = t
+	return y
+}
+
+func (y *YoutubeBuilder) Owner() *YoutubeBuilder {
+	y.m.ChatOwner = true
+	return y
+}
+
+func (y *YoutubeBuilder) Moderator() *YoutubeBuilder {
+	y.m.Moderator = true
+	return y
+}
+
+func (y *YoutubeBuilder) Clear() *YoutubeBuilder {
+	y.m.Type = clearMsg
+	return y
+}
+
+func (y *YoutubeBuilder) Build() *youtube.Message {
+	m := y.m
+	return &m
+}
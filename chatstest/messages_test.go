@@ -0,0 +1,24 @@
+package chatstest
+
+import "testing"
+
+func TestTwitchBuilderCopies(t *testing.T) {
+	b := Twitch().From("u").Channel("a").Text("Kappa hi").Emote("25", "Kappa").Tag("id", "1").
+		Badge("vip", "1").ReplyTo("p", "other", "body")
+	first := b.Build()
+	second := b.Build()
+	first.Tags["id"] = "changed"
+	first.Badges["vip"] = "2"
+	first.Emotes["Kappa"].Positions[0].Start = 5
+	first.Reply.Body = "changed"
+	if second.Tags["id"] != "1" || second.Badges["vip"] != "1" || second.Emotes["Kappa"].Positions[0].Start != 0 ||
+		second.Reply.Body != "body" {
+		t.Errorf("second message is changed with first: %+v", second)
+	}
+	if _, ok := second.Tags["reply-parent-msg-id"]; ok {
+		t.Error("reply tags should not be in Tags")
+	}
+	if got := second.Reply.MsgID; got != "p" {
+		t.Errorf("Reply.MsgID = %v, want %v", got, "p")
+	}
+}
@@ -0,0 +1,112 @@
+package chatstest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// NewOAuthServer starts fake of twitch oauth /validate and /token.
+// Set twitch.OAuthURL = s.URL() to use it
+func NewOAuthServer() *OAuthServer {
+	s := &OAuthServer{tokens: map[string]oauthToken{}, refresh: map[string]string{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/validate", s.validate)
+	mux.HandleFunc("/token", s.token)
+	s.server = httptest.NewServer(mux)
+	return s
+}
+
+type oauthToken struct {
+	login     string
+	userID    string
+	scopes    []string
+	expiresIn int
+	valid     bool
+}
+
+// OAuthServer validates tokens and rotates them by refresh tokens.
+// Refreshed access token is old one with suffix "+"
+type OAuthServer struct {
+	server    *httptest.Server
+	tokens    map[string]oauthToken
+	refresh   map[string]string
+	refreshed int
+	locker    sync.RWMutex
+}
+
+// URL of api, use as twitch.OAuthURL
+func (s *OAuthServer) URL() string {
+	return s.server.URL
+}
+
+func (s *OAuthServer) Close() {
+	s.server.Close()
+}
+
+// AddToken adds valid access token with refresh token
+func (s *OAuthServer) AddToken(access, refresh, login string, scopes ...string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.tokens[access] = oauthToken{login: login, userID: login + "-id", scopes: scopes, expiresIn: 14400, valid: true}
+	if refresh != "" {
+		s.refresh[refresh] = access
+	}
+}
+
+// Expire makes access token invalid
+func (s *OAuthServer) Expire(access string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	t := s.tokens[access]
+	t.valid = false
+	s.tokens[access] = t
+}
+
+// Refreshed is count of successful refreshes
+func (s *OAuthServer) Refreshed() int {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	return s.refreshed
+}
+
+func oauthError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": code, "message": message})
+}
+
+func (s *OAuthServer) validate(w http.ResponseWriter, r *http.Request) {
+	access := strings.TrimPrefix(r.Header.Get("Authorization"), "OAuth ")
+	s.locker.RLock()
+	t, ok := s.tokens[access]
+	s.locker.RUnlock()
+	if !ok || !t.valid {
+		oauthError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	writeJSON(w, map[string]interface{}{"client_id": "client", "login": t.login, "user_id": t.userID,
+		"scopes": t.scopes, "expires_in": t.expiresIn})
+}
+
+func (s *OAuthServer) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	old, ok := s.refresh[r.PostForm.Get("refresh_token")]
+	if r.PostForm.Get("grant_type") != "refresh_token" || !ok {
+		oauthError(w, http.StatusBadRequest, "Invalid refresh token")
+		return
+	}
+	delete(s.refresh, r.PostForm.Get("refresh_token"))
+	t := s.tokens[old]
+	t.valid = true
+	access, refresh := old+"+", r.PostForm.Get("refresh_token")+"+"
+	s.tokens[access] = t
+	s.refresh[refresh] = access
+	s.refreshed++
+	writeJSON(w, map[string]interface{}{"access_token": access, "refresh_token": refresh,
+		"expires_in": t.expiresIn, "scope": t.scopes, "token_type": "bearer"})
+}
@@ -0,0 +1,191 @@
+package chatstest
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/FireGM/chats/peka2tv"
+	"github.com/graarh/golang-socketio"
+	"github.com/graarh/golang-socketio/transport"
+)
+
+type pekaChannelReq struct {
+	Channel string `json:"channel"`
+}
+
+type pekaLoginReq struct {
+	Token string `json:"token"`
+}
+
+type pekaPublishReq struct {
+	Channel string       `json:"channel"`
+	Text    string       `json:"text"`
+	From    peka2tv.User `json:"from"`
+}
+
+type pekaAck struct {
+	Status string      `json:"status"`
+	Result interface{} `json:"result"`
+}
+
+// NewPeka2tvServer starts fake socket.io chat of peka2tv.
+// Use it with peka2tv.Bot.SetServer(s.Host(), s.Port())
+func NewPeka2tvServer() *Peka2tvServer {
+	s := &Peka2tvServer{clients: map[*gosocketio.Channel]bool{}, scripts: map[string][]peka2tv.Message{}}
+	s.sio = gosocketio.NewServer(transport.GetDefaultWebsocketTransport())
+	s.sio.On(gosocketio.OnConnection, func(c *gosocketio.Channel) {
+		s.locker.Lock()
+		s.clients[c] = true
+		s.locker.Unlock()
+	})
+	s.sio.On(gosocketio.OnDisconnection, func(c *gosocketio.Channel) {
+		s.locker.Lock()
+		delete(s.clients, c)
+		s.locker.Unlock()
+	})
+	s.sio.On("/chat/login", func(c *gosocketio.Channel, req pekaLoginReq) pekaAck {
+		s.record("/chat/login", req)
+		if s.RejectAuth || (s.Token != "" && req.Token != s.Token) {
+			return pekaAck{Status: "error", Result: map[string]string{"message": "invalid token"}}
+		}
+		return pekaAck{Status: "ok", Result: map[string]interface{}{}}
+	})
+	s.sio.On("/chat/join", func(c *gosocketio.Channel, req pekaChannelReq) pekaAck {
+		s.record("/chat/join", req)
+		c.Join(req.Channel)
+		s.locker.RLock()
+		script := append([]peka2tv.Message(nil), s.scripts[req.Channel]...)
+		s.locker.RUnlock()
+		go func() {
+			for _, m := range script {
+				c.Emit("/chat/message", m)
+			}
+		}()
+		return pekaAck{Status: "ok", Result: map[string]interface{}{}}
+	})
+	s.sio.On("/chat/leave", func(c *gosocketio.Channel, req pekaChannelReq) pekaAck {
+		s.record("/chat/leave", req)
+		c.Leave(req.Channel)
+		return pekaAck{Status: "ok", Result: map[string]interface{}{}}
+	})
+	s.sio.On("/chat/publish", func(c *gosocketio.Channel, req pekaPublishReq) pekaAck {
+		s.record("/chat/publish", req)
+		s.Message(peka2tv.Message{Channel: req.Channel, From: req.From, Text: req.Text})
+		return pekaAck{Status: "ok", Result: map[string]interface{}{}}
+	})
+	mux := http.NewServeMux()
+	mux.Handle("/socket.io/", s.sio)
+	s.server = httptest.NewServer(mux)
+	return s
+}
+
+// Peka2tvEvent is event received from client
+type Peka2tvEvent struct {
+	Method string
+	Data   interface{}
+}
+
+// Peka2tvServer handles /chat/login, /chat/join, /chat/leave and /chat/publish events
+type Peka2tvServer struct {
+	// Token, if set, is only accepted login token
+	Token string
+	// RejectAuth makes server answer every login with error
+	RejectAuth bool
+
+	sio      *gosocketio.Server
+	server   *httptest.Server
+	clients  map[*gosocketio.Channel]bool
+	scripts  map[string][]peka2tv.Message
+	received []Peka2tvEvent
+	lastID   int
+	locker   sync.RWMutex
+}
+
+func (s *Peka2tvServer) record(method string, data interface{}) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.received = append(s.received, Peka2tvEvent{Method: method, Data: data})
+}
+
+// Host of server for peka2tv.Bot.SetServer
+func (s *Peka2tvServer) Host() string {
+	host, _, _ := net.SplitHostPort(strings.TrimPrefix(s.server.URL, "http://"))
+	return host
+}
+
+// Port of server for peka2tv.Bot.SetServer
+func (s *Peka2tvServer) Port() int {
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(s.server.URL, "http://"))
+	p, _ := strconv.Atoi(port)
+	return p
+}
+
+func (s *Peka2tvServer) Close() {
+	s.DisconnectAll()
+	s.server.Close()
+}
+
+// Script sets messages sent to client right after it joins channel
+func (s *Peka2tvServer) Script(channel string, messages ...peka2tv.Message) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.scripts[channel] = append(s.scripts[channel], messages...)
+}
+
+// Message sends chat message to clients joined to m.Channel, id is assigned if empty
+func (s *Peka2tvServer) Message(m peka2tv.Message) {
+	s.locker.Lock()
+	if m.ID == 0 {
+		s.lastID++
+		m.ID = s.lastID
+	}
+	s.locker.Unlock()
+	s.sio.BroadcastTo(m.Channel, "/chat/message", m)
+}
+
+// Remove sends removing of message with id
+func (s *Peka2tvServer) Remove(channel string, id int) {
+	s.sio.BroadcastTo(channel, "/chat/message/remove", peka2tv.Message{ID: id, Channel: channel})
+}
+
+// InjectMalformed sends events with payload of wrong type to every client
+func (s *Peka2tvServer) InjectMalformed() {
+	for _, c := range s.clientList() {
+		c.Emit("/chat/message", "not a message")
+		c.Emit("/chat/message", []int{1, 2, 3})
+		c.Emit("/chat/message/remove", nil)
+	}
+}
+
+// DisconnectAll drops all client connections
+func (s *Peka2tvServer) DisconnectAll() {
+	for _, c := range s.clientList() {
+		c.Close()
+	}
+}
+
+// Connections is count of connected clients
+func (s *Peka2tvServer) Connections() int {
+	return len(s.clientList())
+}
+
+// Received returns all events got from clients
+func (s *Peka2tvServer) Received() []Peka2tvEvent {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	return append([]Peka2tvEvent(nil), s.received...)
+}
+
+func (s *Peka2tvServer) clientList() []*gosocketio.Channel {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	var res []*gosocketio.Channel
+	for c := range s.clients {
+		res = append(res, c)
+	}
+	return res
+}
@@ -0,0 +1,410 @@
+package chatstest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MalformedIRC is lines which are not valid twitch chat
+var MalformedIRC = []string{
+	"",
+	"@",
+	"@badges=;emotes=25:0-99999 :a!a@a.tmi.twitch.tv PRIVMSG #x :Kappa",
+	":tmi.twitch.tv",
+	"PRIVMSG",
+	"\x00\x01garbage",
+}
+
+// NewTwitchServer starts fake twitch irc server on random localhost port.
+// Use it with twitch.Bot.SetServer(s.Addr())
+func NewTwitchServer() (*TwitchServer, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &TwitchServer{listener: l, clients: map[*twitchClient]bool{},
+		scripts: map[string][]string{}, suspended: map[string]bool{}, rejects: map[string][2]string{},
+		chatters: map[string]map[string]bool{}}
+	go s.accept()
+	return s, nil
+}
+
+// TwitchServer speaks subset of twitch irc: PASS, NICK, CAP, JOIN, PART, PRIVMSG, PING
+type TwitchServer struct {
+	// Token, if set, is only accepted PASS value (without "oauth:")
+	Token string
+	// RejectAuth makes server answer every login with failed authentication
+	RejectAuth bool
+
+	listener  net.Listener
+	clients   map[*twitchClient]bool
+	scripts   map[string][]string
+	received  []string
+	lastID    int
+	suspended map[string]bool
+	rejects   map[string][2]string
+	chatters  map[string]map[string]bool
+	muted     bool
+	locker    sync.RWMutex
+}
+
+type twitchClient struct {
+	conn     net.Conn
+	nick     string
+	pass     string
+	channels map[string]bool
+	caps     map[string]bool
+	server   *TwitchServer
+	locker   sync.Mutex
+}
+
+func (c *twitchClient) send(line string) error {
+	if c.server.isMuted() {
+		return nil
+	}
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	_, err := fmt.Fprint(c.conn, line+"\r\n")
+	return err
+}
+
+func (c *twitchClient) hasCap(name string) bool {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	return c.caps[name]
+}
+
+func (c *twitchClient) joined(ch string) bool {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	return c.channels[ch]
+}
+
+// Addr is host:port of server
+func (s *TwitchServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *TwitchServer) Close() error {
+	err := s.listener.Close()
+	s.DisconnectAll()
+	return err
+}
+
+// Script sets lines which are sent to client right after it joins channel
+func (s *TwitchServer) Script(channel string, lines ...string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.scripts[channel] = append(s.scripts[channel], lines...)
+}
+
+// Broadcast sends raw line to every connected client
+func (s *TwitchServer) Broadcast(line string) {
+	for _, c := range s.clientList() {
+		c.send(line)
+	}
+}
+
+// SendToChannel sends raw line to clients joined to channel
+func (s *TwitchServer) SendToChannel(channel, line string) {
+	for _, c := range s.clientList() {
+		if c.joined(channel) {
+			c.send(line)
+		}
+	}
+}
+
+// Privmsg sends chat message from user to channel
+func (s *TwitchServer) Privmsg(channel, user, text string) {
+	s.locker.Lock()
+	s.lastID++
+	id := s.lastID
+	s.locker.Unlock()
+	s.SendToChannel(channel, fmt.Sprintf("@display-name=%s;id=msg-%d;mod=0;room-id=1;tmi-sent-ts=%d :%s!%s@%s.tmi.twitch.tv PRIVMSG #%s :%s",
+		user, id, time.Now().UnixNano()/int64(time.Millisecond), user, user, user, channel, text))
+}
+
+// UserJoin adds chatter to channel, clients with twitch.tv/membership get JOIN
+func (s *TwitchServer) UserJoin(channel, user string) {
+	s.locker.Lock()
+	if s.chatters[channel] == nil {
+		s.chatters[channel] = map[string]bool{}
+	}
+	s.chatters[channel][user] = true
+	s.locker.Unlock()
+	s.sendMembership(channel, fmt.Sprintf(":%s!%s@%s.tmi.twitch.tv JOIN #%s", user, user, user, channel))
+}
+
+// UserPart removes chatter from channel, clients with twitch.tv/membership get PART
+func (s *TwitchServer) UserPart(channel, user string) {
+	s.locker.Lock()
+	delete(s.chatters[channel], user)
+	s.locker.Unlock()
+	s.sendMembership(channel, fmt.Sprintf(":%s!%s@%s.tmi.twitch.tv PART #%s", user, user, user, channel))
+}
+
+func (s *TwitchServer) sendMembership(channel, line string) {
+	for _, c := range s.clientList() {
+		if c.joined(channel) && c.hasCap("twitch.tv/membership") {
+			c.send(line)
+		}
+	}
+}
+
+// Suspend makes JOIN to channel fail with msg_channel_suspended
+func (s *TwitchServer) Suspend(channel string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.suspended[channel] = true
+}
+
+// Reject makes PRIVMSG to channel answered with NOTICE with msgID instead of USERSTATE,
+// empty msgID accepts messages again
+func (s *TwitchServer) Reject(channel, msgID, text string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if msgID == "" {
+		delete(s.rejects, channel)
+		return
+	}
+	s.rejects[channel] = [2]string{msgID, text}
+}
+
+// Reconnect asks every client to move to new connection
+func (s *TwitchServer) Reconnect() {
+	s.Broadcast(":tmi.twitch.tv RECONNECT")
+}
+
+// Mute makes server handle lines without sending anything, like half-open connection
+func (s *TwitchServer) Mute(muted bool) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.muted = muted
+}
+
+// SetToken changes only accepted PASS value when clients are already connected
+func (s *TwitchServer) SetToken(token string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.Token = token
+}
+
+// Whisper sends private message from user to client with nick
+func (s *TwitchServer) Whisper(from, to, text string) {
+	line := fmt.Sprintf("@display-name=%s;message-id=1;thread-id=1_2 :%s!%s@%s.tmi.twitch.tv WHISPER %s :%s",
+		from, from, from, from, to, text)
+	for _, c := range s.clientList() {
+		if c.nick == to {
+			c.send(line)
+		}
+	}
+}
+
+// InjectMalformed sends MalformedIRC lines to every client
+func (s *TwitchServer) InjectMalformed() {
+	for _, line := range MalformedIRC {
+		s.Broadcast(line)
+	}
+}
+
+// DisconnectAll drops all client connections
+func (s *TwitchServer) DisconnectAll() {
+	for _, c := range s.clientList() {
+		c.conn.Close()
+	}
+}
+
+// Connections is count of connected clients
+func (s *TwitchServer) Connections() int {
+	return len(s.clientList())
+}
+
+// Received returns all lines got from clients
+func (s *TwitchServer) Received() []string {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	return append([]string(nil), s.received...)
+}
+
+// WaitFor waits until client sent line with prefix
+func (s *TwitchServer) WaitFor(prefix string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		for _, line := range s.Received() {
+			if strings.HasPrefix(line, prefix) {
+				return true
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func (s *TwitchServer) isMuted() bool {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	return s.muted
+}
+
+func (s *TwitchServer) clientList() []*twitchClient {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	var res []*twitchClient
+	for c := range s.clients {
+		res = append(res, c)
+	}
+	return res
+}
+
+func (s *TwitchServer) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		c := &twitchClient{conn: conn, channels: map[string]bool{}, caps: map[string]bool{}, server: s}
+		s.locker.Lock()
+		s.clients[c] = true
+		s.locker.Unlock()
+		go s.serve(c)
+	}
+}
+
+func (s *TwitchServer) serve(c *twitchClient) {
+	defer func() {
+		c.conn.Close()
+		s.locker.Lock()
+		delete(s.clients, c)
+		s.locker.Unlock()
+	}()
+	reader := textproto.NewReader(bufio.NewReader(c.conn))
+	for {
+		line, err := reader.ReadLine()
+		if err != nil {
+			return
+		}
+		s.locker.Lock()
+		s.received = append(s.received, line)
+		s.locker.Unlock()
+		if !s.handle(c, line) {
+			return
+		}
+	}
+}
+
+func (s *TwitchServer) handle(c *twitchClient, line string) bool {
+	cmd, rest := line, ""
+	if i := strings.Index(line, " "); i > 0 {
+		cmd, rest = line[:i], line[i+1:]
+	}
+	if strings.HasPrefix(line, "@") {
+		// client tags like reply-parent-msg-id
+		if i := strings.Index(rest, " "); i > 0 {
+			cmd, rest = rest[:i], rest[i+1:]
+		} else {
+			cmd, rest = rest, ""
+		}
+	}
+	switch cmd {
+	case "PASS":
+		c.pass = strings.TrimPrefix(rest, "oauth:")
+	case "NICK":
+		c.nick = rest
+		s.locker.RLock()
+		rejected := s.RejectAuth || (s.Token != "" && c.pass != s.Token && !strings.HasPrefix(c.nick, "justinfan"))
+		s.locker.RUnlock()
+		if rejected {
+			c.send(":tmi.twitch.tv NOTICE * :Login authentication failed")
+			return false
+		}
+		for _, l := range []string{
+			":tmi.twitch.tv 001 %s :Welcome, GLHF!",
+			":tmi.twitch.tv 002 %s :Your host is tmi.twitch.tv",
+			":tmi.twitch.tv 003 %s :This server is rather new",
+			":tmi.twitch.tv 004 %s :-",
+			":tmi.twitch.tv 375 %s :-",
+			":tmi.twitch.tv 372 %s :You are in a maze of twisty passages, all alike.",
+			":tmi.twitch.tv 376 %s :>",
+		} {
+			c.send(fmt.Sprintf(l, c.nick))
+		}
+	case "CAP":
+		c.locker.Lock()
+		for _, name := range strings.Fields(strings.TrimPrefix(strings.TrimPrefix(rest, "REQ "), ":")) {
+			c.caps[name] = true
+		}
+		c.locker.Unlock()
+		c.send(":tmi.twitch.tv CAP * ACK " + strings.TrimPrefix(rest, "REQ "))
+	case "PING":
+		c.send(":tmi.twitch.tv PONG tmi.twitch.tv " + rest)
+	case "JOIN":
+		for _, ch := range strings.Split(rest, ",") {
+			ch = strings.TrimPrefix(ch, "#")
+			s.locker.RLock()
+			suspended := s.suspended[ch]
+			s.locker.RUnlock()
+			if suspended {
+				c.send(fmt.Sprintf("@msg-id=msg_channel_suspended :tmi.twitch.tv NOTICE #%s :This channel has been suspended.", ch))
+				continue
+			}
+			c.locker.Lock()
+			c.channels[ch] = true
+			c.locker.Unlock()
+			c.send(fmt.Sprintf(":%s!%s@%s.tmi.twitch.tv JOIN #%s", c.nick, c.nick, c.nick, ch))
+			names := []string{c.nick}
+			if c.hasCap("twitch.tv/membership") {
+				s.locker.RLock()
+				for user := range s.chatters[ch] {
+					names = append(names, user)
+				}
+				s.locker.RUnlock()
+			}
+			c.send(fmt.Sprintf(":%s.tmi.twitch.tv 353 %s = #%s :%s", c.nick, c.nick, ch, strings.Join(names, " ")))
+			c.send(fmt.Sprintf(":%s.tmi.twitch.tv 366 %s #%s :End of /NAMES list", c.nick, c.nick, ch))
+			c.send(fmt.Sprintf("@badges=;color=;display-name=%s;emote-sets=0;mod=0;subscriber=0;user-type= :tmi.twitch.tv USERSTATE #%s",
+				c.nick, ch))
+			c.send(fmt.Sprintf("@emote-only=0;followers-only=-1;r9k=0;room-id=1;slow=0;subs-only=0 :tmi.twitch.tv ROOMSTATE #%s", ch))
+			s.locker.RLock()
+			script := append([]string(nil), s.scripts[ch]...)
+			s.locker.RUnlock()
+			for _, l := range script {
+				c.send(l)
+			}
+		}
+	case "PART":
+		ch := strings.TrimPrefix(rest, "#")
+		c.locker.Lock()
+		delete(c.channels, ch)
+		c.locker.Unlock()
+		c.send(fmt.Sprintf(":%s!%s@%s.tmi.twitch.tv PART #%s", c.nick, c.nick, c.nick, ch))
+	case "PRIVMSG":
+		spl := strings.SplitN(rest, " :", 2)
+		s.locker.RLock()
+		reject, rejected := s.rejects[strings.TrimPrefix(spl[0], "#")]
+		s.locker.RUnlock()
+		if rejected {
+			c.send(fmt.Sprintf("@msg-id=%s :tmi.twitch.tv NOTICE %s :%s", reject[0], spl[0], reject[1]))
+			return true
+		}
+		if len(spl) == 2 && strings.HasPrefix(spl[0], "#") && !isCommand(spl[1]) {
+			c.send(fmt.Sprintf("@badges=;color=;display-name=%s;emote-sets=0;mod=0;subscriber=0;user-type= :tmi.twitch.tv USERSTATE %s",
+				c.nick, spl[0]))
+		}
+	case "QUIT":
+		return false
+	}
+	return true
+}
+
+// isCommand is true for chat commands like .ban, twitch doesn't answer them with USERSTATE
+func isCommand(text string) bool {
+	if !strings.HasPrefix(text, ".") && !strings.HasPrefix(text, "/") {
+		return false
+	}
+	return !strings.HasPrefix(text[1:], "me ")
+}
@@ -0,0 +1,219 @@
+package chatstest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/FireGM/chats/youtube"
+)
+
+// NewYoutubeServer starts fake of youtube data api used by youtube package.
+// Use it with youtube.Bot.SetURL(s.URL())
+func NewYoutubeServer() *YoutubeServer {
+	s := &YoutubeServer{lives: map[string]youtubeLive{}, messages: map[string][]youtube.MessageResp{},
+		PollingInterval: 100}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search", s.search)
+	mux.HandleFunc("/videos", s.videos)
+	mux.HandleFunc("/liveChat/messages", s.liveChatMessages)
+	mux.HandleFunc("/liveChat/bans", s.bans)
+	s.server = httptest.NewServer(s.faults(mux))
+	return s
+}
+
+type youtubeLive struct {
+	videoID string
+	chatID  string
+}
+
+// YoutubeRequest is request received by server
+type YoutubeRequest struct {
+	Method string
+	Path   string
+	Query  string
+	Body   string
+}
+
+// YoutubeServer serves search, videos, liveChat/messages and liveChat/bans endpoints
+type YoutubeServer struct {
+	// PollingInterval returned in pollingIntervalMillis
+	PollingInterval int
+
+	server    *httptest.Server
+	lives     map[string]youtubeLive
+	messages  map[string][]youtube.MessageResp
+	received  []YoutubeRequest
+	failCount int
+	failCode  int
+	malformed int
+	locker    sync.RWMutex
+}
+
+// URL of api for youtube.Bot.SetURL
+func (s *YoutubeServer) URL() string {
+	return s.server.URL
+}
+
+func (s *YoutubeServer) Close() {
+	s.server.Close()
+}
+
+// StartLive makes channel live with video and chat
+func (s *YoutubeServer) StartLive(channelID, videoID, chatID string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.lives[channelID] = youtubeLive{videoID: videoID, chatID: chatID}
+}
+
+// EndLive removes live stream of channel
+func (s *YoutubeServer) EndLive(channelID string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	delete(s.lives, channelID)
+}
+
+// Message adds message to live chat
+func (s *YoutubeServer) Message(chatID, authorChannelID, author, text string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	var m youtube.MessageResp
+	m.Snippet.Type = "textMessageEvent"
+	m.Snippet.HasDisplayContent = true
+	m.Snippet.DisplayMessage = text
+	m.Snippet.PublishedAt = time.Now()
+	m.AuthorDetails.ChannelID = authorChannelID
+	m.AuthorDetails.DisplayName = author
+	s.messages[chatID] = append(s.messages[chatID], m)
+}
+
+// Fail makes next n requests fail with http status code
+func (s *YoutubeServer) Fail(n, code int) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.failCount = n
+	s.failCode = code
+}
+
+// Malformed makes next n responses broken json
+func (s *YoutubeServer) Malformed(n int) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.malformed = n
+}
+
+// Received returns all requests got from clients
+func (s *YoutubeServer) Received() []YoutubeRequest {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	return append([]YoutubeRequest(nil), s.received...)
+}
+
+func (s *YoutubeServer) faults(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		s.locker.Lock()
+		s.received = append(s.received, YoutubeRequest{Method: r.Method, Path: r.URL.Path,
+			Query: r.URL.RawQuery, Body: string(body)})
+		if s.failCount > 0 {
+			s.failCount--
+			code := s.failCode
+			s.locker.Unlock()
+			http.Error(w, `{"error":{"code":`+strconv.Itoa(code)+`}}`, code)
+			return
+		}
+		if s.malformed > 0 {
+			s.malformed--
+			s.locker.Unlock()
+			w.Write([]byte(`{"items":[{"snippet":`))
+			return
+		}
+		s.locker.Unlock()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *YoutubeServer) search(w http.ResponseWriter, r *http.Request) {
+	s.locker.RLock()
+	live, ok := s.lives[r.URL.Query().Get("channelId")]
+	s.locker.RUnlock()
+	var resp youtube.ChanResp
+	if ok {
+		var item youtube.ChannelInfo
+		item.ID.VideoID = live.videoID
+		resp.Items = append(resp.Items, item)
+		resp.PageInfo.TotalResults = 1
+	}
+	writeJSON(w, resp)
+}
+
+func (s *YoutubeServer) videos(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	var resp youtube.StreamResp
+	s.locker.RLock()
+	for _, live := range s.lives {
+		if live.videoID == id {
+			resp.Items = append(resp.Items, youtube.VideoInfo{
+				LiveStreamingDetails: youtube.LiveStreamingDetails{ActiveLiveChatID: live.chatID}})
+		}
+	}
+	s.locker.RUnlock()
+	resp.PageInfo.TotalResults = len(resp.Items)
+	writeJSON(w, resp)
+}
+
+func (s *YoutubeServer) liveChatMessages(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		var req struct {
+			Snippet struct {
+				LiveChatID         string `json:"liveChatId"`
+				TextMessageDetails struct {
+					MessageText string `json:"messageText"`
+				} `json:"textMessageDetails"`
+			} `json:"snippet"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.Message(req.Snippet.LiveChatID, "bot", "bot", req.Snippet.TextMessageDetails.MessageText)
+		writeJSON(w, req)
+		return
+	}
+	chatID := r.URL.Query().Get("liveChatId")
+	s.locker.RLock()
+	_, ok := s.messages[chatID]
+	for _, live := range s.lives {
+		if live.chatID == chatID {
+			ok = true
+		}
+	}
+	resp := youtube.MessagesResp{PollingIntervalMillis: s.PollingInterval,
+		Items: append([]youtube.MessageResp(nil), s.messages[chatID]...)}
+	s.locker.RUnlock()
+	if !ok {
+		http.Error(w, `{"error":{"code":404,"message":"liveChatNotFound"}}`, http.StatusNotFound)
+		return
+	}
+	resp.PageInfo.TotalResults = len(resp.Items)
+	writeJSON(w, resp)
+}
+
+func (s *YoutubeServer) bans(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Write([]byte(`{"kind":"youtube#liveChatBan"}`))
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
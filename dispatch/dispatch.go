@@ -0,0 +1,184 @@
+// Package dispatch runs message handlers on bounded pool of workers,
+// keeping order of messages of each channel.
+package dispatch
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/FireGM/chats/interfaces"
+)
+
+// Overflow is policy used when queue of worker is full
+type Overflow int
+
+const (
+	// Block waits until queue has free place, reader of chat is stalled
+	Block Overflow = iota
+	// DropOldest removes oldest queued message of worker
+	DropOldest
+	// DropNewest discards message being dispatched
+	DropNewest
+)
+
+// Options of dispatcher, zero values are replaced by defaults
+type Options struct {
+	Workers   int
+	QueueSize int
+	Overflow  Overflow
+}
+
+// Stats are counters of dispatcher
+type Stats struct {
+	Dispatched    uint64
+	Handled       uint64
+	DroppedOldest uint64
+	DroppedNewest uint64
+	Blocked       uint64
+}
+
+// New starts dispatcher with workers, by default 4 workers with queue of 1024 and Block policy
+func New(opts Options) *Dispatcher {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1024
+	}
+	d := &Dispatcher{opts: opts, workers: make([]*worker, opts.Workers)}
+	for i := range d.workers {
+		w := &worker{size: opts.QueueSize}
+		w.cond = sync.NewCond(&w.locker)
+		d.workers[i] = w
+		go w.run(d)
+	}
+	return d
+}
+
+type Dispatcher struct {
+	// first for 64-bit alignment of atomic counters
+	stats   Stats
+	opts    Options
+	workers []*worker
+}
+
+// Dispatch queues f, functions with same key are called in order of dispatching
+func (d *Dispatcher) Dispatch(key string, f func()) {
+	atomic.AddUint64(&d.stats.Dispatched, 1)
+	d.workers[d.index(key)].push(d, f)
+}
+
+// DispatchMessage queues call of handler, key is chat and channel of message.
+// Nil handler is skipped
+func (d *Dispatcher) DispatchMessage(handler func(interfaces.Message, interfaces.Bot), m interfaces.Message, b interfaces.Bot) {
+	if handler == nil {
+		return
+	}
+	d.Dispatch(Key(m), func() { handler(m, b) })
+}
+
+// Wrap makes handler which dispatches messages to handler
+func (d *Dispatcher) Wrap(handler func(interfaces.Message, interfaces.Bot)) func(interfaces.Message, interfaces.Bot) {
+	return func(m interfaces.Message, b interfaces.Bot) {
+		d.DispatchMessage(handler, m, b)
+	}
+}
+
+// Stats returns copy of counters
+func (d *Dispatcher) Stats() Stats {
+	return Stats{
+		Dispatched:    atomic.LoadUint64(&d.stats.Dispatched),
+		Handled:       atomic.LoadUint64(&d.stats.Handled),
+		DroppedOldest: atomic.LoadUint64(&d.stats.DroppedOldest),
+		DroppedNewest: atomic.LoadUint64(&d.stats.DroppedNewest),
+		Blocked:       atomic.LoadUint64(&d.stats.Blocked),
+	}
+}
+
+// Close stops workers after queued functions are done
+func (d *Dispatcher) Close() {
+	for _, w := range d.workers {
+		w.close()
+	}
+}
+
+// Key of message for ordering, chat name and channel
+func Key(m interfaces.Message) string {
+	return m.GetChatName() + "/" + m.GetChannelName()
+}
+
+func (d *Dispatcher) index(key string) int {
+	if len(d.workers) == 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(d.workers)))
+}
+
+type worker struct {
+	queue  []func()
+	size   int
+	closed bool
+	locker sync.Mutex
+	cond   *sync.Cond
+}
+
+func (w *worker) push(d *Dispatcher, f func()) {
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	if w.closed {
+		atomic.AddUint64(&d.stats.DroppedNewest, 1)
+		return
+	}
+	if len(w.queue) >= w.size {
+		switch d.opts.Overflow {
+		case DropNewest:
+			atomic.AddUint64(&d.stats.DroppedNewest, 1)
+			return
+		case DropOldest:
+			w.queue[0] = nil
+			w.queue = w.queue[1:]
+			atomic.AddUint64(&d.stats.DroppedOldest, 1)
+		default:
+			atomic.AddUint64(&d.stats.Blocked, 1)
+			for len(w.queue) >= w.size && !w.closed {
+				w.cond.Wait()
+			}
+			if w.closed {
+				atomic.AddUint64(&d.stats.DroppedNewest, 1)
+				return
+			}
+		}
+	}
+	w.queue = append(w.queue, f)
+	w.cond.Broadcast()
+}
+
+func (w *worker) run(d *Dispatcher) {
+	for {
+		w.locker.Lock()
+		for len(w.queue) == 0 && !w.closed {
+			w.cond.Wait()
+		}
+		if len(w.queue) == 0 && w.closed {
+			w.locker.Unlock()
+			return
+		}
+		f := w.queue[0]
+		w.queue[0] = nil
+		w.queue = w.queue[1:]
+		w.cond.Broadcast()
+		w.locker.Unlock()
+		f()
+		atomic.AddUint64(&d.stats.Handled, 1)
+	}
+}
+
+func (w *worker) close() {
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	w.closed = true
+	w.cond.Broadcast()
+}
@@ -0,0 +1,145 @@
+package dispatch
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDispatchOrderPerKey(t *testing.T) {
+	d := New(Options{Workers: 3, QueueSize: 8})
+	defer d.Close()
+	var locker sync.Mutex
+	got := map[string][]int{}
+	var wg sync.WaitGroup
+	keys := []string{"twitch/a", "twitch/b", "goodgame/1", "peka2tv/all"}
+	for i := 0; i < 100; i++ {
+		for _, k := range keys {
+			k, i := k, i
+			wg.Add(1)
+			d.Dispatch(k, func() {
+				locker.Lock()
+				got[k] = append(got[k], i)
+				locker.Unlock()
+				wg.Done()
+			})
+		}
+	}
+	wg.Wait()
+	for _, k := range keys {
+		if len(got[k]) != 100 {
+			t.Fatalf("%q. handled %d, want 100", k, len(got[k]))
+		}
+		for i, v := range got[k] {
+			if v != i {
+				t.Errorf("%q. position %d = %d, out of order", k, i, v)
+				break
+			}
+		}
+	}
+	if s := d.Stats(); s.Dispatched != 400 || s.Handled != 400 {
+		t.Errorf("Stats() = %+v, want 400 dispatched and handled", s)
+	}
+}
+
+func TestDispatchOverflow(t *testing.T) {
+	tests := []struct {
+		name     string
+		overflow Overflow
+		want     []string
+		stats    Stats
+	}{
+		{
+			name:     "drop newest",
+			overflow: DropNewest,
+			want:     []string{"0", "1", "2"},
+			stats:    Stats{Dispatched: 5, Handled: 3, DroppedNewest: 2},
+		},
+		{
+			name:     "drop oldest",
+			overflow: DropOldest,
+			want:     []string{"0", "3", "4"},
+			stats:    Stats{Dispatched: 5, Handled: 3, DroppedOldest: 2},
+		},
+	}
+	for _, tt := range tests {
+		d := New(Options{Workers: 1, QueueSize: 2, Overflow: tt.overflow})
+		release := make(chan struct{})
+		started := make(chan struct{})
+		var got []string
+		var locker sync.Mutex
+		for i := 0; i < 5; i++ {
+			s := strconv.Itoa(i)
+			d.Dispatch("k", func() {
+				if s == "0" {
+					close(started)
+					<-release
+				}
+				locker.Lock()
+				got = append(got, s)
+				locker.Unlock()
+			})
+			if i == 0 {
+				<-started
+			}
+		}
+		close(release)
+		d.Close()
+		for d.Stats().Handled < tt.stats.Handled {
+			time.Sleep(time.Millisecond)
+		}
+		locker.Lock()
+		if len(got) != len(tt.want) {
+			t.Errorf("%q. handled %v, want %v", tt.name, got, tt.want)
+		}
+		for i := range got {
+			if i < len(tt.want) && got[i] != tt.want[i] {
+				t.Errorf("%q. handled %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+		locker.Unlock()
+		if s := d.Stats(); s != tt.stats {
+			t.Errorf("%q. Stats() = %+v, want %+v", tt.name, s, tt.stats)
+		}
+	}
+}
+
+func TestHolder(t *testing.T) {
+	var h Holder
+	h.Open()
+	own := h.Get()
+	if h.Get() != own {
+		t.Errorf("Get() returned new dispatcher before Close")
+	}
+	h.Close()
+	if h.Get() != own {
+		t.Errorf("Get() after Close = new dispatcher, want closed one")
+	}
+	own.Dispatch("key", func() { t.Errorf("function is called by closed dispatcher") })
+	h.Open()
+	if h.Get() == own {
+		t.Errorf("Open() kept closed dispatcher")
+	}
+
+	shared := New(Options{Workers: 1})
+	defer shared.Close()
+	reopened := h.Get()
+	h.Set(shared)
+	h.Open()
+	h.Close()
+	if h.Get() != shared {
+		t.Errorf("Get() = %p, want shared %p", h.Get(), shared)
+	}
+	done := make(chan bool)
+	shared.Dispatch("key", func() { close(done) })
+	<-done
+	if got := reopened.Stats().DroppedNewest; got != 0 {
+		t.Errorf("DroppedNewest = %v, want %v", got, 0)
+	}
+	reopened.Dispatch("key", func() {})
+	if got := reopened.Stats().DroppedNewest; got != 1 {
+		t.Errorf("DroppedNewest of replaced = %v, want %v", got, 1)
+	}
+}
@@ -0,0 +1,56 @@
+package dispatch
+
+import "sync"
+
+// Holder keeps dispatcher of bot. Default dispatcher is created by Open and
+// stopped by Close, dispatcher given to Set is shared and never closed by holder
+type Holder struct {
+	d      *Dispatcher
+	own    bool
+	closed bool
+	locker sync.Mutex
+}
+
+// Open creates default dispatcher if there is none or own one is closed, call it on Connect
+func (h *Holder) Open() {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+	if h.d == nil || h.closed {
+		h.d = New(Options{})
+		h.own, h.closed = true, false
+	}
+}
+
+// Get returns dispatcher, default one is created if there is none. After Close
+// it returns closed dispatcher, functions dispatched to it are dropped
+func (h *Holder) Get() *Dispatcher {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+	if h.d == nil {
+		h.d = New(Options{})
+		h.own = true
+	}
+	return h.d
+}
+
+// Set replaces dispatcher, own one is closed
+func (h *Holder) Set(d *Dispatcher) {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+	h.closeLocked()
+	h.d, h.own, h.closed = d, false, false
+}
+
+// Close stops own dispatcher, call it on Disconnect
+func (h *Holder) Close() {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+	h.closeLocked()
+}
+
+func (h *Holder) closeLocked() {
+	if h.own && !h.closed {
+		h.d.Close()
+		h.closed = true
+	}
+}
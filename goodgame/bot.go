@@ -9,10 +9,13 @@ import (
 
 	"log"
 
+	"github.com/FireGM/chats/dispatch"
 	"github.com/FireGM/chats/interfaces"
 	"github.com/gorilla/websocket"
 )
 
+const chatURL = "ws://chat.goodgame.ru:8081/chat/websocket"
+
 func New(handleFunc func(interfaces.Message, interfaces.Bot)) *Bot {
 	return &Bot{handleFunc: handleFunc}
 }
@@ -36,47 +39,117 @@ type Bot struct {
 	username   string
 	login      string
 	pass       string
+	url        string
+	dispatcher dispatch.Holder
+}
+
+// SetDispatcher replaces dispatcher of handler calls, it can be shared between bots
+// and is not closed by Disconnect
+func (b *Bot) SetDispatcher(d *dispatch.Dispatcher) {
+	b.dispatcher.Set(d)
+}
+
+// SetURL changes websocket url of chat server
+func (b *Bot) SetURL(url string) {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	b.url = url
+}
+
+func (b *Bot) chatURL() string {
+	b.locker.RLock()
+	defer b.locker.RUnlock()
+	if b.url != "" {
+		return b.url
+	}
+	return chatURL
 }
 
 func (b *Bot) Connect() error {
-	wsClient, _, err := websocket.DefaultDialer.Dial("ws://chat.goodgame.ru:8081/chat/websocket", nil)
+	wsClient, _, err := websocket.DefaultDialer.Dial(b.chatURL(), nil)
 	if err != nil {
 		return err
 	}
+	b.locker.Lock()
 	b.conn = wsClient
+	b.channels = map[string]time.Time{}
+	b.locker.Unlock()
+	b.dispatcher.Open()
 	once.Do(goUpdater)
-	go b.reader()
+	go b.reader(wsClient)
 	return nil
 }
 
 func (b *Bot) Disconnect() error {
-	err := b.conn.Close()
-	if err != nil {
-		return err
-	}
+	b.locker.Lock()
 	b.disconnect = true
-	return nil
+	conn := b.conn
+	b.locker.Unlock()
+	b.dispatcher.Close()
+	return conn.Close()
 }
 
+func (b *Bot) isDisconnected() bool {
+	b.locker.RLock()
+	defer b.locker.RUnlock()
+	return b.disconnect
+}
+
+// reconnectDelay is pause between failed dials of reconnect
+var reconnectDelay = time.Second
+
+// reconnect dials until success or Disconnect, then logins and joins channels again
 func (b *Bot) reconnect() {
-	wsClient, _, err := websocket.DefaultDialer.Dial("ws://chat.goodgame.ru:8081/chat/websocket", nil)
-	if err != nil {
+	var wsClient *websocket.Conn
+	for {
+		if b.isDisconnected() {
+			return
+		}
+		var err error
+		wsClient, _, err = websocket.DefaultDialer.Dial(b.chatURL(), nil)
+		if err == nil {
+			break
+		}
 		log.Println(err)
-		go b.reconnect()
+		time.Sleep(reconnectDelay)
+	}
+	b.locker.Lock()
+	if b.disconnect {
+		b.locker.Unlock()
+		wsClient.Close()
+		return
 	}
 	b.conn = wsClient
-	if b.login != "" && b.pass != "" {
-		b.LoginByPass(b.login, b.pass)
+	login, pass := b.login, b.pass
+	var channels []string
+	for ch := range b.channels {
+		channels = append(channels, ch)
+	}
+	b.locker.Unlock()
+	go b.reader(wsClient)
+	if login != "" && pass != "" {
+		b.LoginByPass(login, pass)
 	}
-	go b.reader()
+	for _, ch := range channels {
+		b.write(GGruct{Type: "join", Data: map[string]string{"channel_id": ch}})
+	}
+}
+
+// write sends frame, websocket allows only one writer at time
+func (b *Bot) write(v interface{}) error {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	return b.conn.WriteJSON(v)
 }
 
 func (b *Bot) LoginByPass(login, password string) error {
 	user := getUserByLoginPass(login, password)
-	err := b.conn.WriteJSON(GGruct{Type: "auth", Data: AuthStructToken{UserID: user.ID, Token: user.Token}})
+	err := b.LoginByChatToken(user.ID, user.Token)
 	if err == nil {
+		b.locker.Lock()
 		b.login = login
 		b.pass = password
+		b.locker.Unlock()
 	}
 	return err
 }
@@ -91,35 +164,46 @@ func (b *Bot) LoginByToken(token string) error {
 	if err != nil {
 		return err
 	}
-	return b.conn.WriteJSON(GGruct{Type: "auth", Data: AuthStructToken{Token: chatToken.Token, UserID: userId}})
+	return b.LoginByChatToken(userId, chatToken.Token)
+}
+
+// LoginByChatToken sends auth with already known chat token
+func (b *Bot) LoginByChatToken(userID int, token string) error {
+	return b.write(GGruct{Type: "auth", Data: AuthStructToken{UserID: userID, Token: token}})
 }
 
+// Join joins channel by id, channels are joined again after reconnect
 func (b *Bot) Join(ch string) error {
-	err := b.conn.WriteJSON(GGruct{Type: "join", Data: map[string]string{"channel_id": ch}})
+	err := b.write(GGruct{Type: "join", Data: map[string]string{"channel_id": ch}})
+	if err == nil {
+		b.locker.Lock()
+		b.channels[ch] = time.Now()
+		b.locker.Unlock()
+	}
 	return err
 }
 
 func (b *Bot) Leave(ch string) error {
-	err := b.conn.WriteJSON(GGruct{Type: "unjoin", Data: map[string]string{"channel_id": ch}})
-	return err
+	b.locker.Lock()
+	delete(b.channels, ch)
+	b.locker.Unlock()
+	return b.write(GGruct{Type: "unjoin", Data: map[string]string{"channel_id": ch}})
 }
 
 func (b *Bot) SendMessageToChan(ch string, message string) error {
-	return b.conn.WriteJSON(GGruct{Type: "send_message", Data: MessageReq{ChannelId: ch, Text: message}})
+	return b.write(GGruct{Type: "send_message", Data: MessageReq{ChannelId: ch, Text: message}})
 }
 
 func (b *Bot) Ban(channelId, userId string) error {
 	st := GGruct{Type: "ban", Data: BanUser{ChannelId: channelId, BanChannel: channelId, UserId: userId, Duration: 72000,
 		DeleteMessage: true, ShowBan: true, Reason: "20 minutes"}}
-	err := b.conn.WriteJSON(st)
-	return err
+	return b.write(st)
 }
 
 func (b *Bot) Timeout(channelId, userId string, t int) error {
 	st := GGruct{Type: "ban", Data: BanUser{ChannelId: channelId, BanChannel: channelId, UserId: userId, Duration: t,
 		DeleteMessage: true, ShowBan: true, Reason: "20 minutes"}}
-	err := b.conn.WriteJSON(st)
-	return err
+	return b.write(st)
 }
 
 func (b *Bot) JoinBySlug(slug string) error {
@@ -130,18 +214,23 @@ func (b *Bot) JoinBySlug(slug string) error {
 	return b.Join(strconv.Itoa(id))
 }
 
-func (b *Bot) reader() {
+func (b *Bot) reader(conn *websocket.Conn) {
 	for {
-		var data json.RawMessage
-		gg := GGruct{Data: &data}
-		err := b.conn.ReadJSON(&gg)
+		_, frame, err := conn.ReadMessage()
 		if err != nil {
 			log.Println(err)
-			if !b.disconnect {
+			if !b.isDisconnected() {
 				go b.reconnect()
 			}
 			return
 		}
+		var data json.RawMessage
+		gg := GGruct{Data: &data}
+		if err := json.Unmarshal(frame, &gg); err != nil {
+			// broken frame is skipped, connection is fine
+			log.Println(err)
+			continue
+		}
 		// fmt.Println(gg.Type, string(data))
 		switch gg.Type {
 		case "welcome":
@@ -160,16 +249,18 @@ func (b *Bot) reader() {
 			err := json.Unmarshal(data, &message)
 			if err != nil {
 				log.Println(err)
+				continue
 			}
-			b.handleFunc(&message, b)
+			b.dispatcher.Get().DispatchMessage(b.handleFunc, &message, b)
 		case "user_ban":
 			var message MessageBan
 			err := json.Unmarshal(data, &message)
 			if err != nil {
 				log.Println(err)
+				continue
 			}
 			message.Type = clearMsg
-			b.handleFunc(&message, b)
+			b.dispatcher.Get().DispatchMessage(b.handleFunc, &message, b)
 		}
 	}
 }
@@ -0,0 +1,136 @@
+package goodgame_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/FireGM/chats/chatstest"
+	"github.com/FireGM/chats/goodgame"
+	"github.com/FireGM/chats/interfaces"
+)
+
+type inbox struct {
+	messages []interfaces.Message
+	locker   sync.Mutex
+}
+
+func (i *inbox) handle(m interfaces.Message, b interfaces.Bot) {
+	i.locker.Lock()
+	defer i.locker.Unlock()
+	i.messages = append(i.messages, m)
+}
+
+func (i *inbox) list() []interfaces.Message {
+	i.locker.Lock()
+	defer i.locker.Unlock()
+	return append([]interfaces.Message(nil), i.messages...)
+}
+
+func waitUntil(t *testing.T, what string, f func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !f() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func received(s *chatstest.GoodGameServer, typ string) int {
+	n := 0
+	for _, f := range s.Received() {
+		if f.Type == typ {
+			n++
+		}
+	}
+	return n
+}
+
+func TestBot(t *testing.T) {
+	s := chatstest.NewGoodGameServer()
+	defer s.Close()
+	var in inbox
+	b := goodgame.New(in.handle)
+	b.SetURL(s.URL())
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+	if err := b.LoginByChatToken(7, "token"); err != nil {
+		t.Fatal(err)
+	}
+	b.Join("10")
+	waitUntil(t, "join", func() bool { return received(s, "join") == 1 })
+
+	s.Message("10", 1, "viewer", "hello")
+	s.Message("11", 1, "viewer", "not joined")
+	s.InjectMalformed()
+	b.SendMessageToChan("10", "from bot")
+	b.Timeout("10", "1", 60)
+	waitUntil(t, "messages", func() bool { return len(in.list()) >= 3 })
+
+	got := in.list()
+	if len(got) != 3 {
+		t.Fatalf("messages = %v, want 3", len(got))
+	}
+	if got[0].GetTextMessage() != "hello" || got[0].GetUserFrom() != "viewer" || got[0].GetChannelName() != "10" {
+		t.Errorf("message = %+v, want hello from viewer", got[0])
+	}
+	if got[1].GetTextMessage() != "from bot" || got[1].GetUserFrom() != "user7" {
+		t.Errorf("message = %+v, want message of bot", got[1])
+	}
+	if !got[2].IsClearMessage() || got[2].GetUID() != "1" {
+		t.Errorf("message = %+v, want ban of user 1", got[2])
+	}
+}
+
+func TestBotReconnect(t *testing.T) {
+	s := chatstest.NewGoodGameServer()
+	defer s.Close()
+	var in inbox
+	b := goodgame.New(in.handle)
+	b.SetURL(s.URL())
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+	b.Join("10")
+	waitUntil(t, "join", func() bool { return received(s, "join") == 1 })
+
+	s.DisconnectAll()
+	waitUntil(t, "reconnect", func() bool { return s.Connections() == 1 && received(s, "join") == 2 })
+	s.Message("10", 1, "viewer", "after reconnect")
+	waitUntil(t, "message", func() bool { return len(in.list()) == 1 })
+}
+
+func TestBotDisconnectStopsReconnect(t *testing.T) {
+	s := chatstest.NewGoodGameServer()
+	defer s.Close()
+	var dials int32
+	refused := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&dials, 1)
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer refused.Close()
+	b := goodgame.New(nil)
+	b.SetURL(s.URL())
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	b.SetURL("ws" + strings.TrimPrefix(refused.URL, "http"))
+	s.DisconnectAll()
+	waitUntil(t, "failed dials", func() bool { return atomic.LoadInt32(&dials) >= 2 })
+	b.Disconnect()
+	time.Sleep(50 * time.Millisecond)
+	n := atomic.LoadInt32(&dials)
+	time.Sleep(100 * time.Millisecond)
+	if got := atomic.LoadInt32(&dials); got != n {
+		t.Errorf("dials after Disconnect = %v, want %v", got, n)
+	}
+}
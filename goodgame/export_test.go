@@ -0,0 +1,8 @@
+package goodgame
+
+import "time"
+
+// reconnect quickly in tests of package goodgame_test
+func init() {
+	reconnectDelay = 10 * time.Millisecond
+}
@@ -149,7 +149,7 @@ func (m *Message) parseEmotes() {
 			continue
 		}
 		smileStr := word[1 : len(word)-1]
-		if s, ok := smiles[smileStr]; ok {
+		if s, ok := findSmile(smileStr); ok {
 			if s.ChannelID == 0 || m.checkPremiumChan(s.ChannelID) ||
 				(m.Donat == s.Donat && m.Donat != 0) {
 				m.Emotes[word] = s
@@ -15,6 +15,7 @@ const smilesURL = "https://goodgame.ru/js/minified/global.js"
 
 var once = sync.Once{}
 var smiles = map[string]Smile{}
+var smilesLocker sync.RWMutex
 
 type Smile struct {
 	ID        int    `json:"id"`
@@ -61,6 +62,7 @@ func getSmilesJs() []byte {
 	res, err := client.Get(smilesURL)
 	if err != nil {
 		log.Println(err)
+		return nil
 	}
 	defer res.Body.Close()
 	b, err := ioutil.ReadAll(res.Body)
@@ -71,14 +73,18 @@ func getSmilesJs() []byte {
 }
 
 func parseJs(js []byte) GlobalJs {
-	trimJs := js[bytes.Index(js, []byte("{")):]
+	var g GlobalJs
+	start := bytes.Index(js, []byte("{"))
+	if start < 0 {
+		return g
+	}
+	trimJs := js[start:]
 	trimJs = bytes.Replace(trimJs, []byte("Smiles"), []byte(`"smiles"`), 1)
 	trimJs = bytes.Replace(trimJs, []byte("Channel_Smiles"), []byte(`"Channel_Smiles"`), 1)
 	trimJs = bytes.Replace(trimJs, []byte("timezone_offset"), []byte(`"timezone_offset"`), 1)
 	trimJs = bytes.Replace(trimJs, []byte("icons"), []byte(`"icons"`), 1)
 	trimJs = bytes.Replace(trimJs, []byte("Content_Width"), []byte(`"Content_Width"`), 1)
 	trimJs = bytes.Replace(trimJs, []byte("};"), []byte(`}`), 1)
-	var g GlobalJs
 	err := json.Unmarshal(trimJs, &g)
 	if err != nil {
 		log.Println(err)
@@ -87,6 +93,8 @@ func parseJs(js []byte) GlobalJs {
 }
 
 func parseToSmiles(g GlobalJs) {
+	smilesLocker.Lock()
+	defer smilesLocker.Unlock()
 	var id int
 	var err error
 	var chanID int
@@ -114,3 +122,18 @@ func parseToSmiles(g GlobalJs) {
 	}
 
 }
+
+// AddSmile registers smile for rendering of all messages, useful for tests.
+// It is safe to call concurrently with rendering
+func AddSmile(s Smile) {
+	smilesLocker.Lock()
+	defer smilesLocker.Unlock()
+	smiles[s.Name] = s
+}
+
+func findSmile(name string) (Smile, bool) {
+	smilesLocker.RLock()
+	defer smilesLocker.RUnlock()
+	s, ok := smiles[name]
+	return s, ok
+}
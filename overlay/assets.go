@@ -0,0 +1,95 @@
+package overlay
+
+const indexHTML = `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="UTF-8" />
+	<title>chat overlay</title>
+	<link id="theme" rel="stylesheet" href="themes/default.css">
+	<script>
+	(function() {
+		var params = new URLSearchParams(location.search);
+		var maxLines = parseInt(params.get("max") || "100", 10);
+		if (params.get("theme")) {
+			document.getElementById("theme").href = "themes/" + params.get("theme") + ".css";
+		}
+		function connect() {
+			var proto = location.protocol === "https:" ? "wss://" : "ws://";
+			var path = location.pathname.replace(/[^\/]*$/, "");
+			var ws = new WebSocket(proto + location.host + path + "ws" + location.search);
+			ws.onmessage = function(e) {
+				var ev = JSON.parse(e.data);
+				var list = document.getElementById("msg-list");
+				if (ev.type === "clear") {
+					var lines = list.querySelectorAll("li");
+					for (var i = 0; i < lines.length; i++) {
+						if (lines[i].dataset.chat === ev.chat && lines[i].dataset.uid === ev.uid) {
+							list.removeChild(lines[i]);
+						}
+					}
+					return;
+				}
+				if (ev.type === "delete") {
+					var lines = list.querySelectorAll("li");
+					for (var i = 0; i < lines.length; i++) {
+						if (lines[i].dataset.chat === ev.chat && lines[i].dataset.id === ev.id) {
+							list.removeChild(lines[i]);
+						}
+					}
+					return;
+				}
+				var li = document.createElement("li");
+				li.className = "chat-line " + ev.chat + "-line";
+				li.dataset.chat = ev.chat;
+				li.dataset.uid = ev.uid;
+				li.dataset.id = ev.id || "";
+				li.innerHTML = ev.html;
+				list.appendChild(li);
+				while (list.children.length > maxLines) {
+					list.removeChild(list.firstChild);
+				}
+				window.scrollTo(0, document.body.scrollHeight);
+			};
+			ws.onclose = function() {
+				setTimeout(connect, 2000);
+			};
+		}
+		window.addEventListener("load", connect);
+	})();
+	</script>
+</head>
+<body>
+	<ul id="msg-list"></ul>
+</body>
+</html>
+`
+
+var themes = map[string]string{
+	"default": baseCSS + `
+body { background: #fff; color: #111; }
+.chat-line { border-bottom: 1px solid #eee; }
+`,
+	"dark": baseCSS + `
+body { background: #18181b; color: #efeff1; }
+.chat-line { border-bottom: 1px solid #2a2a2d; }
+`,
+	"transparent": baseCSS + `
+body { background: transparent; color: #fff; text-shadow: 0 0 2px #000, 1px 1px 2px #000; }
+`,
+}
+
+const baseCSS = `
+body { margin: 0; font-family: sans-serif; font-size: 16px; overflow: hidden; }
+#msg-list { list-style: none; margin: 0; padding: 4px; }
+.chat-line { padding: 2px 0; }
+.full-message, .nickname-badge { display: inline; }
+.nickname { display: inline; margin: 0; font-weight: bold; }
+.message { display: inline; word-wrap: break-word; }
+.separator::after { content: ": "; }
+.badge { height: 18px; vertical-align: middle; margin-right: 2px; }
+.smile { height: 28px; vertical-align: middle; }
+.goodgame-subscribe::before { content: "\2605"; color: #e5a00d; }
+.goodgame-moderator::before { content: "\2694"; color: #3fa34d; }
+.chat-owner::before { content: "\265B"; color: #ffd600; }
+.chat-moderator::before { content: "\2694"; color: #5e84f1; }
+`
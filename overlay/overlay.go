@@ -0,0 +1,316 @@
+// Package overlay serves chat messages to browser overlays over websocket.
+package overlay
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FireGM/chats/interfaces"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	eventMessage = "message"
+	eventClear   = "clear"
+	eventDelete  = "delete"
+)
+
+// Options of overlay server, zero values are replaced by defaults
+type Options struct {
+	// HistorySize is count of last messages sent to just connected clients
+	HistorySize int
+	// ClientBuffer is count of messages queued for client, slow client
+	// with full queue is disconnected
+	ClientBuffer int
+	// PingInterval between websocket pings, client without pong during
+	// two intervals is disconnected
+	PingInterval time.Duration
+}
+
+// Event is json sent to overlay clients
+type Event struct {
+	Type     string `json:"type"`
+	ID       string `json:"id,omitempty"`
+	Chat     string `json:"chat"`
+	Channel  string `json:"channel"`
+	UID      string `json:"uid"`
+	User     string `json:"user"`
+	Text     string `json:"text"`
+	HTML     string `json:"html,omitempty"`
+	Color    string `json:"color,omitempty"`
+	Time     int64  `json:"time"`
+	raw      []byte
+	htmlOnly []byte
+}
+
+// New creates overlay server. Server is http.Handler:
+// / - index.html, /ws - websocket, /themes/<name>.css - styles
+func New(opts Options) *Server {
+	if opts.HistorySize == 0 {
+		opts.HistorySize = 50
+	}
+	if opts.ClientBuffer == 0 {
+		opts.ClientBuffer = 256
+	}
+	if opts.PingInterval == 0 {
+		opts.PingInterval = 30 * time.Second
+	}
+	return &Server{opts: opts, clients: map[*client]bool{}}
+}
+
+type Server struct {
+	opts     Options
+	upgrader websocket.Upgrader
+	clients  map[*client]bool
+	history  []*Event
+	locker   sync.RWMutex
+}
+
+// Handle broadcasts message to clients, use it as handler of bots
+func (s *Server) Handle(m interfaces.Message, b interfaces.Bot) {
+	if !visible(m) {
+		return
+	}
+	e := newEvent(m)
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if e.Type == eventDelete {
+		s.history = deleteEvent(s.history, e)
+	}
+	s.history = append(s.history, e)
+	if len(s.history) > s.opts.HistorySize {
+		s.history = s.history[len(s.history)-s.opts.HistorySize:]
+	}
+	for c := range s.clients {
+		if !c.filter.match(e) {
+			continue
+		}
+		select {
+		case c.send <- e:
+		default:
+			log.Println("overlay: slow client dropped", c.conn.RemoteAddr())
+			s.removeLocked(c)
+		}
+	}
+}
+
+// Clients is count of connected clients
+func (s *Server) Clients() int {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	return len(s.clients)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/ws":
+		s.serveWS(w, r)
+	case strings.HasPrefix(r.URL.Path, "/themes/"):
+		name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/themes/"), ".css")
+		css, ok := themes[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/css; charset=utf-8")
+		w.Write([]byte(css))
+	case r.URL.Path == "/" || r.URL.Path == "/index.html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(indexHTML))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println("overlay: upgrade:", err)
+		return
+	}
+	c := &client{conn: conn, send: make(chan *Event, s.opts.ClientBuffer),
+		filter: parseFilter(r), htmlOnly: r.URL.Query().Get("format") == "html"}
+	s.locker.Lock()
+	for _, e := range s.history {
+		if len(c.send) == cap(c.send) {
+			break
+		}
+		if c.filter.match(e) {
+			c.send <- e
+		}
+	}
+	s.clients[c] = true
+	s.locker.Unlock()
+	go c.writer(s.opts.PingInterval)
+	c.reader(s.opts.PingInterval * 2)
+	s.remove(c)
+}
+
+func (s *Server) remove(c *client) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.removeLocked(c)
+}
+
+func (s *Server) removeLocked(c *client) {
+	if _, ok := s.clients[c]; !ok {
+		return
+	}
+	delete(s.clients, c)
+	close(c.send)
+}
+
+type client struct {
+	conn     *websocket.Conn
+	send     chan *Event
+	filter   filter
+	htmlOnly bool
+}
+
+func (c *client) reader(pongWait time.Duration) {
+	defer c.conn.Close()
+	c.conn.SetReadLimit(512)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		if _, _, err := c.conn.NextReader(); err != nil {
+			return
+		}
+	}
+}
+
+func (c *client) writer(pingInterval time.Duration) {
+	ticker := time.NewTicker(pingInterval)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+	for {
+		select {
+		case e, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			data := e.raw
+			if c.htmlOnly {
+				if e.Type != eventMessage {
+					continue
+				}
+				data = e.htmlOnly
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// filter by query: ?platform=twitch,goodgame&channel=lirik
+type filter struct {
+	platforms map[string]bool
+	channels  map[string]bool
+}
+
+func parseFilter(r *http.Request) filter {
+	q := r.URL.Query()
+	return filter{platforms: splitSet(q["platform"]), channels: splitSet(q["channel"])}
+}
+
+func splitSet(values []string) map[string]bool {
+	set := map[string]bool{}
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				set[strings.ToLower(s)] = true
+			}
+		}
+	}
+	return set
+}
+
+func (f filter) match(e *Event) bool {
+	if len(f.platforms) > 0 && !f.platforms[e.Chat] {
+		return false
+	}
+	if len(f.channels) > 0 && !f.channels[strings.ToLower(e.Channel)] {
+		return false
+	}
+	return true
+}
+
+// isPrivate is true for whispers and other messages not visible in channel
+func isPrivate(m interfaces.Message) bool {
+	p, ok := m.(interface {
+		IsPrivate() bool
+	})
+	return ok && p.IsPrivate()
+}
+
+// visible is true for messages of users and for clears and deletions of them,
+// whispers and service messages like NOTICE or ROOMSTATE are not shown
+func visible(m interfaces.Message) bool {
+	if isPrivate(m) {
+		return false
+	}
+	return m.IsFromUser() || m.IsClearMessage() || deletedID(m) != ""
+}
+
+// messageID of message if chat has ids
+func messageID(m interfaces.Message) string {
+	if i, ok := m.(interface {
+		GetMessageID() string
+	}); ok {
+		return i.GetMessageID()
+	}
+	return ""
+}
+
+// deletedID is id of single deleted message, like twitch CLEARMSG
+func deletedID(m interfaces.Message) string {
+	if d, ok := m.(interface {
+		DeletedMessageID() string
+	}); ok {
+		return d.DeletedMessageID()
+	}
+	return ""
+}
+
+// deleteEvent removes deleted message from history
+func deleteEvent(history []*Event, d *Event) []*Event {
+	for i, e := range history {
+		if e.Type == eventMessage && e.Chat == d.Chat && e.ID == d.ID {
+			return append(history[:i:i], history[i+1:]...)
+		}
+	}
+	return history
+}
+
+func newEvent(m interfaces.Message) *Event {
+	e := &Event{Type: eventMessage, ID: messageID(m), Chat: m.GetChatName(), Channel: m.GetChannelName(),
+		UID: m.GetUID(), User: m.GetUserFrom(), Text: m.GetTextMessage(), Time: time.Now().Unix()}
+	if id := deletedID(m); id != "" {
+		e.Type = eventDelete
+		e.ID = id
+	} else if m.IsClearMessage() {
+		e.Type = eventClear
+	} else {
+		e.HTML = string(m.GetRenderFullHTML())
+		e.Color = m.GetColorNickname()
+	}
+	e.raw, _ = json.Marshal(e)
+	e.htmlOnly = []byte(e.HTML)
+	return e
+}
@@ -0,0 +1,165 @@
+package overlay
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FireGM/chats/chatstest"
+	"github.com/FireGM/chats/interfaces"
+	"github.com/FireGM/chats/twitch"
+	"github.com/gorilla/websocket"
+)
+
+func waitUntil(t *testing.T, what string, f func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !f() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func parse(t *testing.T, line string) interfaces.Message {
+	m, err := twitch.Parse(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func dial(t *testing.T, ts *httptest.Server, query string) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func readEvents(t *testing.T, conn *websocket.Conn, n int) []Event {
+	var events []Event
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for len(events) < n {
+		var e Event
+		if err := conn.ReadJSON(&e); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestVisible(t *testing.T) {
+	tests := []struct {
+		name string
+		m    interfaces.Message
+		want bool
+	}{
+		{"privmsg", chatstest.Twitch().From("fan").Text("hi").Build(), true},
+		{"clearchat", chatstest.Twitch().From("fan").Clear().Build(), true},
+		{"clearmsg", parse(t, "@login=fan;target-msg-id=m1 :tmi.twitch.tv CLEARMSG #chan :hi"), true},
+		{"notice", parse(t, "@msg-id=slow_on :tmi.twitch.tv NOTICE #chan :This room is now in slow mode."), false},
+		{"roomstate", parse(t, "@slow=10 :tmi.twitch.tv ROOMSTATE #chan"), false},
+		{"whisper", parse(t, ":fan!fan@fan.tmi.twitch.tv WHISPER bot :secret"), false},
+		{"goodgame", chatstest.GoodGame().From(1, "fan").Text("hi").Build(), true},
+	}
+	for _, tt := range tests {
+		if got := visible(tt.m); got != tt.want {
+			t.Errorf("%q. visible() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	s := New(Options{HistorySize: 2})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	all := dial(t, ts, "")
+	defer all.Close()
+	filtered := dial(t, ts, "?platform=twitch&channel=Chan")
+	defer filtered.Close()
+	waitUntil(t, "clients", func() bool { return s.Clients() == 2 })
+
+	s.Handle(parse(t, "@msg-id=slow_on :tmi.twitch.tv NOTICE #chan :This room is now in slow mode."), nil)
+	s.Handle(chatstest.Twitch().From("fan").Channel("chan").ID("m1").Text("hello").Build(), nil)
+	s.Handle(chatstest.Twitch().From("fan").Channel("other").Text("elsewhere").Build(), nil)
+	s.Handle(chatstest.GoodGame().From(1, "gamer").Channel(5).Text("gg").Build(), nil)
+	s.Handle(parse(t, "@login=fan;target-msg-id=m1 :tmi.twitch.tv CLEARMSG #chan :hello"), nil)
+
+	got := readEvents(t, all, 4)
+	want := []struct{ typ, chat, text string }{
+		{eventMessage, "twitch", "hello"}, {eventMessage, "twitch", "elsewhere"},
+		{eventMessage, "goodgame", "gg"}, {eventDelete, "twitch", "hello"},
+	}
+	for i, w := range want {
+		if got[i].Type != w.typ || got[i].Chat != w.chat || got[i].Text != w.text {
+			t.Errorf("event %v = %+v, want %v %v %v", i, got[i], w.typ, w.chat, w.text)
+		}
+	}
+	if got[3].ID != "m1" {
+		t.Errorf("deleted ID = %v, want %v", got[3].ID, "m1")
+	}
+	got = readEvents(t, filtered, 2)
+	if got[0].Text != "hello" || got[1].Type != eventDelete {
+		t.Errorf("filtered events = %+v", got)
+	}
+
+	// history keeps last messages without deleted one
+	late := dial(t, ts, "")
+	defer late.Close()
+	got = readEvents(t, late, 2)
+	if got[0].Text != "gg" || got[1].Type != eventDelete {
+		t.Errorf("history = %+v", got)
+	}
+}
+
+func TestServerSlowClient(t *testing.T) {
+	conns := make(chan *websocket.Conn, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conns <- conn
+	}))
+	defer ts.Close()
+	peer := dial(t, ts, "")
+	defer peer.Close()
+	conn := <-conns
+	defer conn.Close()
+
+	// client without writer never empties its queue
+	s := New(Options{ClientBuffer: 1})
+	c := &client{conn: conn, send: make(chan *Event, 1)}
+	s.clients[c] = true
+	s.Handle(chatstest.Twitch().From("fan").Text("one").Build(), nil)
+	if got := s.Clients(); got != 1 {
+		t.Fatalf("Clients() = %v, want %v", got, 1)
+	}
+	s.Handle(chatstest.Twitch().From("fan").Text("two").Build(), nil)
+	if got := s.Clients(); got != 0 {
+		t.Errorf("Clients() = %v, want %v", got, 0)
+	}
+	if e := <-c.send; e.Text != "one" {
+		t.Errorf("queued = %v, want %v", e.Text, "one")
+	}
+	if _, ok := <-c.send; ok {
+		t.Errorf("queue of dropped client is not closed")
+	}
+}
+
+func TestEventJSON(t *testing.T) {
+	e := newEvent(chatstest.Twitch().From("fan").Channel("chan").Color("#FF0000").Text("hi").Build())
+	var got map[string]interface{}
+	if err := json.Unmarshal(e.raw, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["type"] != eventMessage || got["user"] != "fan" || got["color"] != "#FF0000" || got["html"] == "" {
+		t.Errorf("event = %v", got)
+	}
+}
@@ -0,0 +1,174 @@
+package overlay
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FireGM/chats/interfaces"
+)
+
+// SSEOptions of server-sent events handler, zero values are replaced by defaults
+type SSEOptions struct {
+	// BufferSize is count of last events kept for resuming by Last-Event-ID
+	BufferSize int
+	// ClientBuffer is count of events queued for client, slow client
+	// with full queue is disconnected
+	ClientBuffer int
+	// Heartbeat is interval of comment lines which keep proxies from closing stream
+	Heartbeat time.Duration
+}
+
+type sseEvent struct {
+	id    uint64
+	event *Event
+}
+
+// NewSSE creates server-sent events handler.
+// Query: ?platform=twitch&channel=lirik&format=html, format=json by default
+func NewSSE(opts SSEOptions) *SSE {
+	if opts.BufferSize == 0 {
+		opts.BufferSize = 500
+	}
+	if opts.ClientBuffer == 0 {
+		opts.ClientBuffer = 256
+	}
+	if opts.Heartbeat == 0 {
+		opts.Heartbeat = 15 * time.Second
+	}
+	return &SSE{opts: opts, clients: map[*sseClient]bool{}}
+}
+
+type SSE struct {
+	opts    SSEOptions
+	ring    []sseEvent
+	lastID  uint64
+	clients map[*sseClient]bool
+	locker  sync.RWMutex
+}
+
+type sseClient struct {
+	send     chan sseEvent
+	filter   filter
+	htmlOnly bool
+}
+
+// Handle sends message to subscribed clients, use it as handler of bots
+func (s *SSE) Handle(m interfaces.Message, b interfaces.Bot) {
+	if !visible(m) {
+		return
+	}
+	event := newEvent(m)
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.lastID++
+	e := sseEvent{id: s.lastID, event: event}
+	s.ring = append(s.ring, e)
+	if len(s.ring) > s.opts.BufferSize {
+		s.ring = s.ring[len(s.ring)-s.opts.BufferSize:]
+	}
+	for c := range s.clients {
+		if !c.filter.match(e.event) {
+			continue
+		}
+		select {
+		case c.send <- e:
+		default:
+			log.Println("overlay: slow sse client dropped")
+			s.removeLocked(c)
+		}
+	}
+}
+
+func (s *SSE) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("lastEventId")
+	}
+	c := &sseClient{send: make(chan sseEvent, s.opts.ClientBuffer), filter: parseFilter(r),
+		htmlOnly: r.URL.Query().Get("format") == "html"}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	s.locker.Lock()
+	var missed []sseEvent
+	if id, err := strconv.ParseUint(lastID, 10, 64); err == nil {
+		for _, e := range s.ring {
+			if e.id > id && c.filter.match(e.event) {
+				missed = append(missed, e)
+			}
+		}
+	}
+	s.clients[c] = true
+	s.locker.Unlock()
+	defer s.remove(c)
+
+	fmt.Fprintf(w, "retry: 2000\n\n")
+	for _, e := range missed {
+		c.write(w, e)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(s.opts.Heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case e, ok := <-c.send:
+			if !ok {
+				return
+			}
+			if c.write(w, e) {
+				flusher.Flush()
+			}
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// write returns false if event was skipped
+func (c *sseClient) write(w http.ResponseWriter, e sseEvent) bool {
+	data := string(e.event.raw)
+	if c.htmlOnly {
+		if e.event.Type != eventMessage {
+			return false
+		}
+		data = e.event.HTML
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\n", e.id, e.event.Type)
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(w, "data: %s\n", strings.TrimSuffix(line, "\r"))
+	}
+	fmt.Fprint(w, "\n")
+	return true
+}
+
+func (s *SSE) remove(c *sseClient) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.removeLocked(c)
+}
+
+func (s *SSE) removeLocked(c *sseClient) {
+	if _, ok := s.clients[c]; !ok {
+		return
+	}
+	delete(s.clients, c)
+	close(c.send)
+}
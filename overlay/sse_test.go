@@ -0,0 +1,153 @@
+package overlay
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FireGM/chats/chatstest"
+)
+
+// sseStream reads blocks of event stream separated by empty lines
+type sseStream struct {
+	res    *http.Response
+	blocks chan string
+}
+
+func openSSE(t *testing.T, ts *httptest.Server, query, lastID string) *sseStream {
+	req, err := http.NewRequest("GET", ts.URL+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &sseStream{res: res, blocks: make(chan string, 100)}
+	go func() {
+		defer close(s.blocks)
+		scanner := bufio.NewScanner(res.Body)
+		var block []string
+		for scanner.Scan() {
+			if scanner.Text() != "" {
+				block = append(block, scanner.Text())
+				continue
+			}
+			s.blocks <- strings.Join(block, "\n")
+			block = nil
+		}
+	}()
+	return s
+}
+
+func (s *sseStream) Close() {
+	s.res.Body.Close()
+}
+
+// next skips retry and ping blocks unless they are wanted
+func (s *sseStream) next(t *testing.T, pings bool) string {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case b, ok := <-s.blocks:
+			if !ok {
+				t.Fatal("stream closed")
+			}
+			if strings.HasPrefix(b, "retry:") || (!pings && b == ": ping") {
+				continue
+			}
+			return b
+		case <-timeout:
+			t.Fatal("timeout waiting for event")
+		}
+	}
+}
+
+func clients(s *SSE) int {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	return len(s.clients)
+}
+
+func TestSSE(t *testing.T) {
+	s := NewSSE(SSEOptions{})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	all := openSSE(t, ts, "", "")
+	defer all.Close()
+	html := openSSE(t, ts, "?format=html&channel=chan", "")
+	defer html.Close()
+	waitUntil(t, "clients", func() bool { return clients(s) == 2 })
+
+	s.Handle(parse(t, "@msg-id=slow_on :tmi.twitch.tv NOTICE #chan :This room is now in slow mode."), nil)
+	s.Handle(chatstest.Twitch().From("fan").Channel("chan").Text("hello").Build(), nil)
+	s.Handle(chatstest.Twitch().From("fan").Channel("chan").Clear().Build(), nil)
+
+	if b := all.next(t, false); !strings.HasPrefix(b, "id: 1\nevent: message\ndata: {") {
+		t.Errorf("event = %q", b)
+	}
+	if b := all.next(t, false); !strings.HasPrefix(b, "id: 2\nevent: clear\n") {
+		t.Errorf("event = %q", b)
+	}
+	// html format skips clear events
+	if b := html.next(t, false); !strings.HasPrefix(b, "id: 1\nevent: message\ndata: <") {
+		t.Errorf("html event = %q", b)
+	}
+}
+
+func TestSSEResume(t *testing.T) {
+	s := NewSSE(SSEOptions{BufferSize: 3})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	for _, text := range []string{"one", "two", "three", "four"} {
+		s.Handle(chatstest.Twitch().From("fan").Channel("chan").Text(text).Build(), nil)
+	}
+
+	resumed := openSSE(t, ts, "", "2")
+	defer resumed.Close()
+	for _, id := range []string{"3", "4"} {
+		if b := resumed.next(t, false); !strings.HasPrefix(b, "id: "+id+"\n") {
+			t.Errorf("event = %q, want id %v", b, id)
+		}
+	}
+	// query is used by clients which can't set header, id 1 is out of buffer
+	query := openSSE(t, ts, "?lastEventId=0", "")
+	defer query.Close()
+	for _, id := range []string{"2", "3", "4"} {
+		if b := query.next(t, false); !strings.HasPrefix(b, "id: "+id+"\n") {
+			t.Errorf("event = %q, want id %v", b, id)
+		}
+	}
+	fresh := openSSE(t, ts, "", "")
+	defer fresh.Close()
+	waitUntil(t, "clients", func() bool { return clients(s) == 3 })
+	s.Handle(chatstest.Twitch().From("fan").Channel("chan").Text("five").Build(), nil)
+	if b := fresh.next(t, false); !strings.HasPrefix(b, "id: 5\n") {
+		t.Errorf("event = %q, want id %v", b, 5)
+	}
+}
+
+func TestSSEHeartbeat(t *testing.T) {
+	s := NewSSE(SSEOptions{Heartbeat: 20 * time.Millisecond})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	stream := openSSE(t, ts, "", "")
+	defer stream.Close()
+	if got := stream.res.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %v, want %v", got, "text/event-stream")
+	}
+	for i := 0; i < 2; i++ {
+		if b := stream.next(t, true); b != ": ping" {
+			t.Errorf("block = %q, want %q", b, ": ping")
+		}
+	}
+	// closed stream removes client
+	stream.Close()
+	waitUntil(t, "removed client", func() bool { return clients(s) == 0 })
+}
@@ -8,6 +8,7 @@ import (
 
 	"log"
 
+	"github.com/FireGM/chats/dispatch"
 	"github.com/FireGM/chats/interfaces"
 	"github.com/graarh/golang-socketio"
 	"github.com/graarh/golang-socketio/transport"
@@ -19,6 +20,7 @@ const (
 )
 
 const chatURL = "chat.peka2.tv"
+const chatPort = 80
 
 func New(handleFunc func(interfaces.Message, interfaces.Bot)) *Bot {
 	return &Bot{handleFunc: handleFunc}
@@ -41,31 +43,52 @@ type Bot struct {
 	username   string
 	userID     int
 	token      string
+	host       string
+	port       int
+	dispatcher dispatch.Holder
+}
+
+// SetDispatcher replaces dispatcher of handler calls, it can be shared between bots
+// and is not closed by Disconnect
+func (b *Bot) SetDispatcher(d *dispatch.Dispatcher) {
+	b.dispatcher.Set(d)
+}
+
+// SetServer changes socket.io chat server
+func (b *Bot) SetServer(host string, port int) {
+	b.host = host
+	b.port = port
 }
 
 func (b *Bot) Connect() error {
+	host, port := chatURL, chatPort
+	if b.host != "" {
+		host, port = b.host, b.port
+	}
 	conn, err := gosocketio.Dial(
-		gosocketio.GetUrl(chatURL, 80, false),
+		gosocketio.GetUrl(host, port, false),
 		transport.GetDefaultWebsocketTransport(),
 	)
 	if err != nil {
 		return err
 	}
 	once.Do(goUpdater)
+	b.dispatcher.Open()
 	conn.On("/chat/message", func(h *gosocketio.Channel, m Message) {
-		b.handleFunc(&m, b)
+		b.dispatcher.Get().DispatchMessage(b.handleFunc, &m, b)
 	})
 	conn.On("/chat/message/remove", func(h *gosocketio.Channel, m Message) {
 		log.Println(m.ID)
 		log.Println(m)
 		m.Type = clearMsg
-		b.handleFunc(&m, b)
+		b.dispatcher.Get().DispatchMessage(b.handleFunc, &m, b)
 	})
 	b.conn = conn
 	return nil
 }
 
 func (b *Bot) Disconnect() error {
+	b.dispatcher.Close()
 	b.conn.Close()
 	return nil
 }
@@ -0,0 +1,99 @@
+package peka2tv_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FireGM/chats/chatstest"
+	"github.com/FireGM/chats/interfaces"
+	"github.com/FireGM/chats/peka2tv"
+)
+
+type inbox struct {
+	messages []interfaces.Message
+	locker   sync.Mutex
+}
+
+func (i *inbox) handle(m interfaces.Message, b interfaces.Bot) {
+	i.locker.Lock()
+	defer i.locker.Unlock()
+	i.messages = append(i.messages, m)
+}
+
+func (i *inbox) list() []interfaces.Message {
+	i.locker.Lock()
+	defer i.locker.Unlock()
+	return append([]interfaces.Message(nil), i.messages...)
+}
+
+func waitUntil(t *testing.T, what string, f func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !f() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func received(s *chatstest.Peka2tvServer, method string) int {
+	n := 0
+	for _, e := range s.Received() {
+		if e.Method == method {
+			n++
+		}
+	}
+	return n
+}
+
+func TestBot(t *testing.T) {
+	s := chatstest.NewPeka2tvServer()
+	defer s.Close()
+	s.Script("stream/1", peka2tv.Message{ID: 1, Channel: "stream/1", From: peka2tv.User{ID: 2, Name: "viewer"},
+		Text: "welcome"})
+	var in inbox
+	b := peka2tv.New(in.handle)
+	b.SetServer(s.Host(), s.Port())
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+	if err := b.Join("stream/1"); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "scripted message", func() bool { return len(in.list()) == 1 })
+
+	s.Message(peka2tv.Message{Channel: "stream/1", From: peka2tv.User{ID: 2, Name: "viewer"}, Text: "hello"})
+	s.Message(peka2tv.Message{Channel: "stream/2", From: peka2tv.User{ID: 2, Name: "viewer"}, Text: "not joined"})
+	s.InjectMalformed()
+	if err := b.SendMessageToChan("stream/1", "from bot"); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "messages", func() bool { return len(in.list()) >= 3 })
+	s.Remove("stream/1", 1)
+	waitUntil(t, "remove", func() bool { return len(in.list()) >= 4 })
+
+	got := in.list()
+	want := []struct{ user, text string }{{"viewer", "welcome"}, {"viewer", "hello"}, {"", "from bot"}}
+	for i, w := range want {
+		if got[i].GetUserFrom() != w.user || got[i].GetTextMessage() != w.text || got[i].GetChannelName() != "stream/1" {
+			t.Errorf("message %v = %v: %v, want %v: %v", i, got[i].GetUserFrom(), got[i].GetTextMessage(), w.user, w.text)
+		}
+	}
+	if !got[3].IsClearMessage() || got[3].GetUID() != "1" {
+		t.Errorf("message = %+v, want remove of message 1", got[3])
+	}
+
+	if err := b.Leave("stream/1"); err != nil {
+		t.Fatal(err)
+	}
+	if n := received(s, "/chat/leave"); n != 1 {
+		t.Errorf("leave events = %v, want %v", n, 1)
+	}
+	s.Message(peka2tv.Message{Channel: "stream/1", Text: "after leave"})
+	time.Sleep(100 * time.Millisecond)
+	if n := len(in.list()); n != 4 {
+		t.Errorf("messages after leave = %v, want %v", n, 4)
+	}
+}
@@ -112,7 +112,7 @@ func (m *Message) GetRenderNicknameHTML() template.HTML {
 		html.EscapeString(m.GetUserFrom()))
 	badge := ""
 	if m.Store.Icon != 0 {
-		badge = `<img class="badge peka-badge" src="` + icons[m.Store.Icon].URL + `">`
+		badge = `<img class="badge peka-badge" src="` + findIcon(m.Store.Icon).URL + `">`
 	}
 	m.NicknameRender = template.HTML(`<div class="nickname-badge peka-nickname-badge">` +
 		badge + nickname + `</div>`)
@@ -183,7 +183,7 @@ func (m *Message) parseEmotes() {
 			continue
 		}
 		smileStr := word[1 : len(word)-1]
-		if s, ok := smiles[smileStr]; ok {
+		if s, ok := findSmile(smileStr); ok {
 			if s.BonusId == 0 || m.checkPerm(s.BonusId) {
 				m.Emotes[word] = Emote{Name: smileStr, Url: s.Url, Code: word}
 			}
@@ -18,6 +18,9 @@ var nickColors = map[int]string{}
 var smilesPerMessage = map[int]int{}
 var icons = map[int]Icon{}
 
+// storeLocker guards smiles and icons, they are changed by updater, AddSmile and AddIcon
+var storeLocker sync.RWMutex
+
 type Icon struct {
 	ID  int    `json:"id"`
 	URL string `json:"url"`
@@ -124,6 +127,8 @@ func requestSmiles(smiles *[]SmileRequest) error {
 }
 
 func parseSmilesPerm(id int, smilesP []string) {
+	storeLocker.Lock()
+	defer storeLocker.Unlock()
 	for _, s := range smilesP {
 		if _, ok := smiles[s]; !ok {
 			smiles[s] = &Smile{BonusId: id}
@@ -134,6 +139,8 @@ func parseSmilesPerm(id int, smilesP []string) {
 }
 
 func parseSmiles(sm []SmileRequest) {
+	storeLocker.Lock()
+	defer storeLocker.Unlock()
 	for _, s := range sm {
 		if _, ok := smiles[s.Code]; !ok {
 			smiles[s.Code] = &Smile{Url: s.URL}
@@ -165,7 +172,40 @@ func requestIcons(ic *[]Icon) error {
 }
 
 func parseIcons(ic []Icon) {
+	storeLocker.Lock()
+	defer storeLocker.Unlock()
 	for _, i := range ic {
 		icons[i.ID] = i
 	}
 }
+
+// AddSmile registers smile for rendering of all messages, useful for tests.
+// It is safe to call concurrently with rendering
+func AddSmile(code, url string, bonusID int) {
+	storeLocker.Lock()
+	defer storeLocker.Unlock()
+	smiles[code] = &Smile{Url: url, BonusId: bonusID}
+}
+
+// AddIcon registers badge icon for rendering of all messages
+func AddIcon(i Icon) {
+	storeLocker.Lock()
+	defer storeLocker.Unlock()
+	icons[i.ID] = i
+}
+
+func findSmile(code string) (Smile, bool) {
+	storeLocker.RLock()
+	defer storeLocker.RUnlock()
+	s, ok := smiles[code]
+	if !ok {
+		return Smile{}, false
+	}
+	return *s, true
+}
+
+func findIcon(id int) Icon {
+	storeLocker.RLock()
+	defer storeLocker.RUnlock()
+	return icons[id]
+}
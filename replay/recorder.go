@@ -0,0 +1,35 @@
+package replay
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/FireGM/chats/interfaces"
+)
+
+// NewRecorder makes archive writer, use Recorder.Handle as handler of bots
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w)}
+}
+
+type Recorder struct {
+	enc    *json.Encoder
+	locker sync.Mutex
+}
+
+func (r *Recorder) Handle(m interfaces.Message, b interfaces.Bot) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	err = r.enc.Encode(Record{Time: time.Now(), Chat: m.GetChatName(), Message: data})
+	if err != nil {
+		log.Println(err)
+	}
+}
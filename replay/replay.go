@@ -0,0 +1,214 @@
+// Package replay plays recorded chat back through interfaces.Bot handlers.
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/FireGM/chats/goodgame"
+	"github.com/FireGM/chats/interfaces"
+	"github.com/FireGM/chats/peka2tv"
+	"github.com/FireGM/chats/twitch"
+	"github.com/FireGM/chats/youtube"
+)
+
+const (
+	// MaxSpeed emits messages without any delay
+	MaxSpeed = 0
+	// OriginalSpeed keeps delays between messages as recorded
+	OriginalSpeed = 1
+)
+
+var errReadOnly = errors.New("replay: recorded chat is read only")
+
+// Record is one line of archive JSONL
+type Record struct {
+	Time    time.Time       `json:"time"`
+	Chat    string          `json:"chat"`
+	Message json.RawMessage `json:"message"`
+}
+
+type entry struct {
+	time    time.Time
+	message interfaces.Message
+}
+
+// New creates bot which plays messages with speed multiplier,
+// 1 - original speed, 2 - twice faster, 0 - as fast as possible
+func New(handle func(interfaces.Message, interfaces.Bot), speed float64) *Bot {
+	return &Bot{handleFunc: handle, speed: speed, channels: map[string]bool{},
+		stop: make(chan struct{}), done: make(chan struct{})}
+}
+
+type Bot struct {
+	entries    []entry
+	channels   map[string]bool
+	handleFunc func(interfaces.Message, interfaces.Bot)
+	speed      float64
+	locker     sync.RWMutex
+	stop       chan struct{}
+	done       chan struct{}
+	stopOnce   sync.Once
+	doneOnce   sync.Once
+}
+
+// LoadJSONL reads archive records, one json object per line
+func (b *Bot) LoadJSONL(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return fmt.Errorf("replay: line %d: %v", line, err)
+		}
+		m, err := decodeMessage(rec.Chat, rec.Message)
+		if err != nil {
+			return fmt.Errorf("replay: line %d: %v", line, err)
+		}
+		b.add(rec.Time, m)
+	}
+	return scanner.Err()
+}
+
+// LoadIRC reads raw twitch irc lines, time of message taken from tmi-sent-ts tag.
+// Unsupported lines are skipped
+func (b *Bot) LoadIRC(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var last time.Time
+	for scanner.Scan() {
+		m, err := twitch.ParseMessage(scanner.Text())
+		if err != nil {
+			continue
+		}
+		if ts, err := strconv.ParseInt(m.Tags["tmi-sent-ts"], 10, 64); err == nil {
+			last = time.Unix(0, ts*int64(time.Millisecond))
+		}
+		b.add(last, &m)
+	}
+	return scanner.Err()
+}
+
+// Add appends message to the end of playlist
+func (b *Bot) Add(t time.Time, m interfaces.Message) {
+	b.add(t, m)
+}
+
+func (b *Bot) add(t time.Time, m interfaces.Message) {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	b.entries = append(b.entries, entry{time: t, message: m})
+}
+
+// Connect starts playing in background
+func (b *Bot) Connect() error {
+	go b.Play()
+	return nil
+}
+
+// Play emits loaded messages to handler and blocks until all of them are played
+// or bot is disconnected
+func (b *Bot) Play() {
+	defer b.doneOnce.Do(func() { close(b.done) })
+	b.locker.RLock()
+	entries := b.entries
+	b.locker.RUnlock()
+	var prev time.Time
+	for i, e := range entries {
+		if i > 0 && b.speed > 0 && !e.time.IsZero() && !prev.IsZero() && e.time.After(prev) {
+			delay := time.Duration(float64(e.time.Sub(prev)) / b.speed)
+			select {
+			case <-time.After(delay):
+			case <-b.stop:
+				return
+			}
+		}
+		select {
+		case <-b.stop:
+			return
+		default:
+		}
+		if !e.time.IsZero() {
+			prev = e.time
+		}
+		if !b.joined(e.message.GetChannelName()) {
+			continue
+		}
+		b.handleFunc(e.message, b)
+	}
+}
+
+// Done closed when playing is finished
+func (b *Bot) Done() <-chan struct{} {
+	return b.done
+}
+
+func (b *Bot) joined(ch string) bool {
+	b.locker.RLock()
+	defer b.locker.RUnlock()
+	if len(b.channels) == 0 {
+		return true
+	}
+	return b.channels[ch]
+}
+
+func (b *Bot) Disconnect() error {
+	b.stopOnce.Do(func() { close(b.stop) })
+	return nil
+}
+
+// Join limits playing to joined channels. Without joins all channels are played
+func (b *Bot) Join(ch string) error {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	b.channels[ch] = true
+	return nil
+}
+
+func (b *Bot) Leave(ch string) error {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	delete(b.channels, ch)
+	return nil
+}
+
+func (b *Bot) SendMessageToChan(ch, message string) error {
+	return errReadOnly
+}
+
+func (b *Bot) Ban(channel, nickname string) error {
+	return errReadOnly
+}
+
+func (b *Bot) Timeout(channel, nickname string, t int) error {
+	return errReadOnly
+}
+
+func decodeMessage(chat string, data json.RawMessage) (interfaces.Message, error) {
+	var m interfaces.Message
+	switch chat {
+	case "twitch":
+		m = &twitch.Message{}
+	case "goodgame":
+		m = &goodgame.Message{}
+	case "peka2tv":
+		m = &peka2tv.Message{}
+	case "youtube":
+		m = &youtube.Message{}
+	default:
+		return nil, fmt.Errorf("unknown chat %q", chat)
+	}
+	err := json.Unmarshal(data, m)
+	return m, err
+}
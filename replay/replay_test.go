@@ -0,0 +1,159 @@
+package replay
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FireGM/chats/interfaces"
+	"github.com/FireGM/chats/twitch"
+)
+
+type played struct {
+	messages []interfaces.Message
+	times    []time.Time
+	locker   sync.Mutex
+}
+
+func (p *played) handle(m interfaces.Message, b interfaces.Bot) {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	p.messages = append(p.messages, m)
+	p.times = append(p.times, time.Now())
+}
+
+func (p *played) texts() []string {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	var texts []string
+	for _, m := range p.messages {
+		texts = append(texts, m.GetTextMessage())
+	}
+	return texts
+}
+
+func TestLoadJSONL(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf)
+	rec.Handle(&twitch.Message{Type: "PRIVMSG", Channel: "a", User: "u", Text: "hello"}, nil)
+	buf.WriteString("\n")
+	rec.Handle(&twitch.Message{Type: "PRIVMSG", Channel: "b", User: "u", Text: "world"}, nil)
+
+	var p played
+	b := New(p.handle, MaxSpeed)
+	if err := b.LoadJSONL(&buf); err != nil {
+		t.Fatal(err)
+	}
+	b.Play()
+	if got := strings.Join(p.texts(), ","); got != "hello,world" {
+		t.Errorf("played = %v, want %v", got, "hello,world")
+	}
+	if _, ok := p.messages[0].(*twitch.Message); !ok {
+		t.Errorf("message = %T, want *twitch.Message", p.messages[0])
+	}
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "broken json", data: `{"chat":"twitch",`},
+		{name: "unknown chat", data: `{"chat":"irc","message":{}}`},
+	}
+	for _, tt := range tests {
+		if err := New(p.handle, MaxSpeed).LoadJSONL(strings.NewReader(tt.data)); err == nil {
+			t.Errorf("%q. LoadJSONL() error = nil, want error", tt.name)
+		}
+	}
+}
+
+func TestLoadIRC(t *testing.T) {
+	data := strings.Join([]string{
+		"@tmi-sent-ts=1000 :u!u@u.tmi.twitch.tv PRIVMSG #a :first",
+		"not irc at all",
+		":tmi.twitch.tv CAP * ACK :twitch.tv/tags",
+		"@tmi-sent-ts=3000 :u!u@u.tmi.twitch.tv PRIVMSG #a :second",
+	}, "\n")
+	b := New(nil, MaxSpeed)
+	if err := b.LoadIRC(strings.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	var texts []string
+	for _, e := range b.entries {
+		if e.message.IsFromUser() {
+			texts = append(texts, e.message.GetTextMessage())
+		}
+	}
+	if got := strings.Join(texts, ","); got != "first,second" {
+		t.Errorf("messages = %v, want %v", got, "first,second")
+	}
+	first, last := b.entries[0].time, b.entries[len(b.entries)-1].time
+	if got := last.Sub(first); got != 2*time.Second {
+		t.Errorf("time between messages = %v, want %v", got, 2*time.Second)
+	}
+}
+
+func TestPlaySpeed(t *testing.T) {
+	start := time.Now()
+	tests := []struct {
+		name     string
+		speed    float64
+		min, max time.Duration
+	}{
+		{name: "max speed", speed: MaxSpeed, min: 0, max: 50 * time.Millisecond},
+		{name: "ten times faster", speed: 10, min: 180 * time.Millisecond, max: time.Second},
+	}
+	for _, tt := range tests {
+		var p played
+		b := New(p.handle, tt.speed)
+		b.Add(start, &twitch.Message{Channel: "a", Text: "1"})
+		b.Add(start.Add(2*time.Second), &twitch.Message{Channel: "a", Text: "2"})
+		b.Play()
+		if len(p.times) != 2 {
+			t.Fatalf("%q. played %v messages, want 2", tt.name, len(p.times))
+		}
+		if got := p.times[1].Sub(p.times[0]); got < tt.min || got > tt.max {
+			t.Errorf("%q. delay = %v, want between %v and %v", tt.name, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestJoin(t *testing.T) {
+	var p played
+	b := New(p.handle, MaxSpeed)
+	for _, ch := range []string{"a", "b", "c", "a"} {
+		b.Add(time.Time{}, &twitch.Message{Channel: ch, Text: ch})
+	}
+	b.Join("a")
+	b.Join("c")
+	b.Leave("c")
+	b.Play()
+	if got := strings.Join(p.texts(), ","); got != "a,a" {
+		t.Errorf("played = %v, want %v", got, "a,a")
+	}
+}
+
+func TestDisconnect(t *testing.T) {
+	var p played
+	b := New(p.handle, OriginalSpeed)
+	start := time.Now()
+	b.Add(start, &twitch.Message{Channel: "a", Text: "now"})
+	b.Add(start.Add(time.Hour), &twitch.Message{Channel: "a", Text: "later"})
+	b.Connect()
+	for len(p.texts()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	b.Disconnect()
+	select {
+	case <-b.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Play is not stopped by Disconnect")
+	}
+	if got := strings.Join(p.texts(), ","); got != "now" {
+		t.Errorf("played = %v, want %v", got, "now")
+	}
+	if err := b.SendMessageToChan("a", "hi"); err != errReadOnly {
+		t.Errorf("SendMessageToChan() error = %v, want %v", err, errReadOnly)
+	}
+}
@@ -0,0 +1,82 @@
+package twitch
+
+import (
+	"strings"
+)
+
+// SetTokenSource sets source of oauth token, it replaces oauth of New.
+// RefreshingSource is refreshed when login is rejected
+func (b *Bot) SetTokenSource(ts TokenSource) {
+	b.tokens = ts
+}
+
+// OnAuthError sets callback for rejected login, bot stops reconnecting
+// if token can't be refreshed
+func (b *Bot) OnAuthError(f func(error, *Bot)) {
+	b.onAuthError = f
+}
+
+func (b *Bot) accessToken() (string, error) {
+	if b.anonymous {
+		return "", nil
+	}
+	if b.tokens == nil {
+		return strings.TrimPrefix(b.oauth, "oauth:"), nil
+	}
+	t, err := b.tokens.Token()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(t.AccessToken, "oauth:"), nil
+}
+
+// openConn connects with token of source. Login rejected by chat is tried once
+// more with refreshed token if source can refresh it. retry is false when
+// reconnecting makes no sense: token is rejected again or source itself fails
+// with *AuthError or *ScopeError, like revoked refresh token
+func (b *Bot) openConn() (retry bool, err error) {
+	for refreshed := false; ; refreshed = true {
+		token, err := b.accessToken()
+		if err != nil {
+			return !b.reportAuthError(err), err
+		}
+		_, err = b.switchConn(token)
+		if _, ok := err.(*AuthError); !ok {
+			return true, err
+		}
+		b.reportAuthError(err)
+		if refreshed || !b.invalidateToken() {
+			return false, err
+		}
+	}
+}
+
+// reportAuthError passes *AuthError and *ScopeError to callback of OnAuthError,
+// it returns false for other errors
+func (b *Bot) reportAuthError(err error) bool {
+	switch err.(type) {
+	case *AuthError, *ScopeError:
+	default:
+		return false
+	}
+	if b.onAuthError != nil {
+		b.onAuthError(err, b)
+	}
+	return true
+}
+
+// invalidateToken makes source refresh token, it is false if source can't refresh
+func (b *Bot) invalidateToken() bool {
+	i, ok := b.tokens.(interface {
+		Invalidate()
+	})
+	if ok {
+		i.Invalidate()
+	}
+	return ok
+}
+
+func isLoginFailure(n *Notice) bool {
+	return n.Channel == "" && (n.Text == "Login authentication failed" ||
+		n.Text == "Improperly formatted auth" || n.Text == "Invalid NICK")
+}
@@ -2,14 +2,17 @@ package twitch
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"log"
+	"math/rand"
 	"net"
 	"net/textproto"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/FireGM/chats/dispatch"
 	"github.com/FireGM/chats/interfaces"
 )
 
@@ -29,6 +32,15 @@ func NewWithRender(name, oauth string, clientId string, handle func(interfaces.M
 	return &Bot{name: name, oauth: oauth, handleFunc: handle}
 }
 
+// NewAnonymous creates read only bot without oauth token, it logins as justinfan
+func NewAnonymous(handle func(interfaces.Message, interfaces.Bot)) *Bot {
+	name := fmt.Sprintf("justinfan%d", 10000+rand.Intn(90000))
+	return &Bot{name: name, anonymous: true, handleFunc: handle}
+}
+
+// ErrAnonymous is returned by sending methods of anonymous bot
+var ErrAnonymous = errors.New("not supported in anonymous mode")
+
 func defaultHandle(m interfaces.Message, b interfaces.Bot) {
 	if !m.IsFromUser() {
 		return
@@ -38,129 +50,318 @@ func defaultHandle(m interfaces.Message, b interfaces.Bot) {
 }
 
 type Bot struct {
-	channels   map[string]*time.Time
+	channels   map[string]*channel
 	name       string
 	oauth      string
 	handleFunc func(interfaces.Message, interfaces.Bot)
-	conn       net.Conn
+	conn       *ircConn
+	connLocker sync.RWMutex
 	locker     sync.RWMutex
 	disconnect bool
+	transport  Transport
+	dispatcher dispatch.Holder
+	anonymous  bool
+	helix      *Helix
+	userID     string
+	tokens     TokenSource
+
+	onAuthError func(error, *Bot)
+
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+	seen         dedupe
+
+	// noReconnect and onDisconnect are used by Pool
+	noReconnect  bool
+	onDisconnect func(*Bot)
+
+	onUserNotice func(UserNoticeEvent, *Bot)
+	onWhisper    func(*Whisper, *Bot)
+	onRoomState  func(old, new RoomState, b *Bot)
+	onSendResult func(SendResult, *Bot)
+	onPresence   func(PresenceEvent, *Bot)
+
+	rooms       map[string]*RoomState
+	roomsLocker sync.RWMutex
+
+	sends       map[string][]*pendingSend
+	sendsLocker sync.Mutex
+
+	presence       *presence
+	presenceLocker sync.RWMutex
+}
+
+// OnUserNotice sets callback for subs, gifts, raids, announcements and other USERNOTICE.
+// Handler of bot gets them too
+func (b *Bot) OnUserNotice(f func(UserNoticeEvent, *Bot)) {
+	b.onUserNotice = f
+}
+
+// OnWhisper sets callback for private messages to bot. Handler of bot doesn't
+// get them, so whispers are never shown as chat
+func (b *Bot) OnWhisper(f func(*Whisper, *Bot)) {
+	b.onWhisper = f
+}
+
+// IsAnonymous is true for bot created by NewAnonymous
+func (b *Bot) IsAnonymous() bool {
+	return b.anonymous
+}
+
+// SetDispatcher replaces dispatcher of handler calls, it can be shared between bots
+// and is not closed by Disconnect
+func (b *Bot) SetDispatcher(d *dispatch.Dispatcher) {
+	b.dispatcher.Set(d)
+}
+
+// SetServer changes irc server address (host:port), default is irc.chat.twitch.tv:6667
+func (b *Bot) SetServer(addr string) {
+	b.transport = TCPTransport{Addr: addr}
+}
+
+// SetTransport changes how bot connects: TCPTransport, TLSTransport or WebSocketTransport
+func (b *Bot) SetTransport(t Transport) {
+	b.transport = t
+}
+
+func (b *Bot) dial() (net.Conn, error) {
+	if b.transport == nil {
+		return TCPTransport{}.Dial()
+	}
+	return b.transport.Dial()
 }
 
 func (b *Bot) Connect() error {
-	var err error
-	b.conn, err = net.Dial("tcp", server+":"+serverPort)
-	if err != nil {
+	b.channels = map[string]*channel{}
+	b.dispatcher.Open()
+	if _, err := b.openConn(); err != nil {
 		return err
 	}
-	go b.read()
-	b.channels = map[string]*time.Time{}
-	b.login()
 	once.Do(goUpdater)
 	return nil
 }
 
 func (b *Bot) Disconnect() error {
-
-	err := b.conn.Close()
-	if err != nil {
-		return err
-	}
+	b.locker.Lock()
 	b.disconnect = true
-	return nil
+	b.locker.Unlock()
+	b.dispatcher.Close()
+	return b.Close()
 }
 
-func (b *Bot) reconnect() error {
-	var err error
-	b.conn, err = net.Dial("tcp", server+":"+serverPort)
-	if err != nil {
-		return err
-	}
-	go b.read()
-	b.login()
-	for ch := range b.channels {
-		b.Join(ch)
-	}
-	return nil
+func (b *Bot) isDisconnected() bool {
+	b.locker.RLock()
+	defer b.locker.RUnlock()
+	return b.disconnect
 }
 
 func (b *Bot) Close() error {
-	return b.conn.Close()
+	c := b.currentConn()
+	if c == nil {
+		return errNotConnected
+	}
+	return c.Close()
 }
 
 func (b *Bot) Send(message string) error {
-	_, err := fmt.Fprintf(b.conn, message+"\r\n")
-	return err
+	c := b.currentConn()
+	if c == nil {
+		return errNotConnected
+	}
+	return c.writeLine(message)
 }
 
 func (b *Bot) Ban(channel, nickname string) error {
+	if b.anonymous {
+		return ErrAnonymous
+	}
+	if b.helix != nil {
+		return b.helixBan(channel, nickname, 0)
+	}
 	return b.SendMessageToChan(channel, fmt.Sprintf(".ban %s", nickname))
 }
 
+// Timeout bans user for t seconds, t must be positive, see Ban
 func (b *Bot) Timeout(channel, nickname string, t int) error {
+	if b.anonymous {
+		return ErrAnonymous
+	}
+	if t <= 0 {
+		return errTimeoutDuration
+	}
+	if b.helix != nil {
+		return b.helixBan(channel, nickname, t)
+	}
 	return b.SendMessageToChan(channel, fmt.Sprintf(".timeout %s %d", nickname, t))
 }
 
 func (b *Bot) SendMessageToChan(ch, message string) error {
-	return b.Send(fmt.Sprintf("PRIVMSG #%s :%s", ch, message))
+	if b.anonymous {
+		return ErrAnonymous
+	}
+	return b.sendPrivmsg(ch, message, fmt.Sprintf("PRIVMSG #%s :%s", ch, message))
+}
+
+// Reply sends message as reply to message with parentMsgID in thread
+func (b *Bot) Reply(ch, parentMsgID, message string) error {
+	if b.anonymous {
+		return ErrAnonymous
+	}
+	return b.sendPrivmsg(ch, message,
+		fmt.Sprintf("@reply-parent-msg-id=%s PRIVMSG #%s :%s", escapeTagValue(parentMsgID), ch, message))
 }
 
+// SendWhisper sends private message to user by helix, chat command /w is not supported by twitch
+func (b *Bot) SendWhisper(user, message string) error {
+	if b.anonymous {
+		return ErrAnonymous
+	}
+	if b.helix == nil {
+		return ErrNoHelix
+	}
+	from, err := b.moderatorID()
+	if err != nil {
+		return err
+	}
+	to, err := b.helixUserID(strings.ToLower(user))
+	if err != nil {
+		return err
+	}
+	return b.helix.SendWhisper(from, to, message)
+}
+
+// Join sends JOIN, state of channel is joining until server confirms it, see Channels.
+// Failed and parting channels are joined again
 func (b *Bot) Join(ch string) error {
+	ch = channelName(ch)
 	b.locker.Lock()
 	defer b.locker.Unlock()
-	if _, ok := b.channels[ch]; ok {
+	if c, ok := b.channels[ch]; ok && c.info.State != ChannelFailed && c.info.State != ChannelParting {
 		return nil
 	}
 	err := b.Send("JOIN #" + ch)
 	if err != nil {
 		return err
 	}
-	n := time.Now()
-	b.channels[ch] = &n
+	b.channels[ch] = newChannel(ch)
 	return nil
 }
 
 func (b *Bot) Leave(ch string) error {
+	ch = channelName(ch)
 	b.locker.Lock()
 	defer b.locker.Unlock()
-	if _, ok := b.channels[ch]; ok {
+	c, ok := b.channels[ch]
+	if !ok {
+		return nil
+	}
+	if c.info.State == ChannelFailed {
+		delete(b.channels, ch)
 		return nil
 	}
 	err := b.Send("PART #" + ch)
 	if err != nil {
 		return err
 	}
-	delete(b.channels, ch)
+	c.info.State = ChannelParting
+	b.forgetRoomState(ch)
 	return nil
 }
 
-func (b *Bot) login() {
-	b.Send("PASS oauth:" + b.oauth)
-	b.Send("NICK " + b.name)
-	b.Send("CAP REQ twitch.tv/tags")
-	b.Send("CAP REQ twitch.tv/commands")
+func (b *Bot) login(c *ircConn, token string) {
+	if !b.anonymous {
+		c.writeLine("PASS oauth:" + token)
+	}
+	c.writeLine("NICK " + b.name)
+	c.writeLine("CAP REQ twitch.tv/tags")
+	c.writeLine("CAP REQ twitch.tv/commands")
+	if b.tracksPresence() {
+		c.writeLine("CAP REQ twitch.tv/membership")
+	}
 }
 
-func (b *Bot) read() {
-	reader := textproto.NewReader(bufio.NewReader(b.conn))
+func (b *Bot) read(c *ircConn) {
+	reader := textproto.NewReader(bufio.NewReader(c))
 	for {
 		line, err := reader.ReadLine()
 		if err != nil {
+			c.Close()
+			if !c.finishLogin(err) {
+				// switchConn gets error of login
+				return
+			}
+			if b.currentConn() != c {
+				// old connection after RECONNECT
+				return
+			}
 			log.Println(err)
-			if !b.disconnect {
+			disconnected := b.isDisconnected()
+			if b.onDisconnect != nil && !disconnected {
+				b.onDisconnect(b)
+			}
+			if !disconnected && !b.noReconnect {
 				b.reconnect()
 			}
 			return
 		}
+		c.touch()
 		// log.Println(line)
-		if strings.HasPrefix(line, "PING") {
-			b.Send(strings.Replace(line, "PING", "PONG", 1))
+		m, err := Parse(line)
+		if err != nil {
 			continue
 		}
-		m, err := ParseMessage(line)
-		if err != nil {
+		if n, ok := m.(*Numeric); ok && n.Code == 1 {
+			c.finishLogin(nil)
+		}
+		if n, ok := m.(*Notice); ok && isLoginFailure(n) {
+			c.finishLogin(&AuthError{Message: n.Text})
+			c.Close()
 			continue
 		}
-		go b.handleFunc(&m, b)
+		if ping, ok := m.(*Message); ok && ping.Type == pingMsg {
+			c.writeLine(strings.Replace(line, "PING", "PONG", 1))
+			continue
+		}
+		if pong, ok := m.(*Message); ok && pong.Type == pongMsg {
+			continue
+		}
+		if _, ok := m.(*Reconnect); ok {
+			go b.migrate(c)
+		}
+		if id := messageID(m); id != "" && !b.seen.add(id) {
+			continue
+		}
+		b.updateMembership(m)
+		b.updateSends(m)
+		b.updatePresence(m)
+		if g, ok := m.(*GlobalUserState); ok && g.UserID != "" {
+			b.setUserID(g.UserID)
+		}
+		if rs, ok := m.(*Message); ok && rs.Type == roomStateMsg {
+			b.updateRoomState(rs)
+		}
+		if n, ok := m.(UserNoticeEvent); ok && b.onUserNotice != nil {
+			b.dispatcher.Get().Dispatch(dispatch.Key(m), func() { b.onUserNotice(n, b) })
+		}
+		if w, ok := m.(*Whisper); ok && b.onWhisper != nil {
+			b.dispatcher.Get().Dispatch(dispatch.Key(m), func() { b.onWhisper(w, b) })
+		}
+		if forHandler(m) {
+			b.dispatcher.Get().DispatchMessage(b.handleFunc, m, b)
+		}
+	}
+}
+
+// handlerTypes are commands passed to handler of bot, other lines like NOTICE,
+// numerics or RECONNECT are used by bot itself, use Parse to get them
+var handlerTypes = map[string]bool{privMsg: true, usernoticeMsg: true, clearMsg: true,
+	userStateMsg: true, joinMsg: true, roomStateMsg: true, clearSingleMsg: true}
+
+func forHandler(m interfaces.Message) bool {
+	if c, ok := m.(interface {
+		command() string
+	}); ok {
+		return handlerTypes[c.command()]
 	}
+	return false
 }
@@ -0,0 +1,57 @@
+package twitch
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestNewAnonymous(t *testing.T) {
+	b := NewAnonymous(nil)
+	if !strings.HasPrefix(b.name, "justinfan") || !b.IsAnonymous() {
+		t.Fatalf("NewAnonymous() name = %v, anonymous = %v", b.name, b.IsAnonymous())
+	}
+	if err := b.SendMessageToChan("chan", "hi"); err != ErrAnonymous {
+		t.Errorf("SendMessageToChan() = %v, want %v", err, ErrAnonymous)
+	}
+	if err := b.Ban("chan", "user"); err != ErrAnonymous {
+		t.Errorf("Ban() = %v, want %v", err, ErrAnonymous)
+	}
+	if err := b.Timeout("chan", "user", 10); err != ErrAnonymous {
+		t.Errorf("Timeout() = %v, want %v", err, ErrAnonymous)
+	}
+	if err := b.Reply("chan", "id", "hi"); err != ErrAnonymous {
+		t.Errorf("Reply() = %v, want %v", err, ErrAnonymous)
+	}
+	// helix is not requested by anonymous bot
+	b.helix = NewHelix("client", "token")
+	for name, f := range map[string]func() error{
+		"Ban":              func() error { return b.Ban("chan", "user") },
+		"Timeout":          func() error { return b.Timeout("chan", "user", 10) },
+		"Unban":            func() error { return b.Unban("chan", "user") },
+		"DeleteMessage":    func() error { return b.DeleteMessage("chan", "id") },
+		"ClearChat":        func() error { return b.ClearChat("chan") },
+		"SetSlowMode":      func() error { return b.SetSlowMode("chan", 10) },
+		"SetEmoteOnly":     func() error { return b.SetEmoteOnly("chan", true) },
+		"SetFollowersOnly": func() error { return b.SetFollowersOnly("chan", 0) },
+		"SetSubsOnly":      func() error { return b.SetSubsOnly("chan", true) },
+		"SetUniqueChat":    func() error { return b.SetUniqueChat("chan", true) },
+	} {
+		if err := f(); err != ErrAnonymous {
+			t.Errorf("%v() with helix = %v, want %v", name, err, ErrAnonymous)
+		}
+	}
+	b.helix = nil
+
+	client, server := net.Pipe()
+	defer server.Close()
+	go b.login(newIRCConn(client), "")
+	line, err := bufio.NewReader(server).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "NICK " + b.name + "\r\n"; line != want {
+		t.Errorf("login() first line = %q, want %q", line, want)
+	}
+}
@@ -0,0 +1,200 @@
+package twitch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CheermotesURL is endpoint of cheermotes catalog in format of helix /bits/cheermotes
+var CheermotesURL = "https://api.twitch.tv/helix/bits/cheermotes"
+
+// CheermotesToken is bearer token sent to CheermotesURL, if set
+var CheermotesToken = ""
+
+var cheermotes = map[string]*Cheermote{}
+var cheermotesLocker sync.RWMutex
+
+// CheermoteTier is image and color of cheermote for amount starting from MinBits.
+// Images are by theme (dark, light), format (animated, static) and scale (1, 1.5, 2, 3, 4)
+type CheermoteTier struct {
+	ID       string                                  `json:"id"`
+	MinBits  int                                     `json:"min_bits"`
+	Color    string                                  `json:"color"`
+	CanCheer bool                                    `json:"can_cheer"`
+	Images   map[string]map[string]map[string]string `json:"images"`
+}
+
+// Image of tier for current EmoteTheme and EmoteFormat
+func (t *CheermoteTier) Image(scale string) string {
+	format := "animated"
+	if EmoteFormat == "static" {
+		format = "static"
+	}
+	return t.Images[EmoteTheme][format][scale]
+}
+
+type Cheermote struct {
+	Prefix string          `json:"prefix"`
+	Type   string          `json:"type"`
+	Tiers  []CheermoteTier `json:"tiers"`
+}
+
+// Tier for amount of bits, nil if no tier
+func (c *Cheermote) Tier(amount int) *CheermoteTier {
+	var tier *CheermoteTier
+	for i := range c.Tiers {
+		if c.Tiers[i].MinBits <= amount && (tier == nil || c.Tiers[i].MinBits > tier.MinBits) {
+			tier = &c.Tiers[i]
+		}
+	}
+	return tier
+}
+
+// Cheer is cheermote token of message like Cheer100
+type Cheer struct {
+	Prefix string         `json:"prefix"`
+	Amount int            `json:"amount"`
+	Tier   *CheermoteTier `json:"tier,omitempty"`
+}
+
+// SetCheermotes replaces catalog of cheermotes
+func SetCheermotes(list []Cheermote) {
+	m := make(map[string]*Cheermote, len(list))
+	for i := range list {
+		c := list[i]
+		sort.Slice(c.Tiers, func(a, b int) bool { return c.Tiers[a].MinBits < c.Tiers[b].MinBits })
+		m[strings.ToLower(c.Prefix)] = &c
+	}
+	cheermotesLocker.Lock()
+	cheermotes = m
+	cheermotesLocker.Unlock()
+}
+
+func getCheermote(prefix string) *Cheermote {
+	cheermotesLocker.RLock()
+	defer cheermotesLocker.RUnlock()
+	return cheermotes[strings.ToLower(prefix)]
+}
+
+// parseCheer splits word like Cheer100 to known prefix and amount
+func parseCheer(word string) (Cheer, bool) {
+	i := len(word)
+	for i > 0 && word[i-1] >= '0' && word[i-1] <= '9' {
+		i--
+	}
+	if i == 0 || i == len(word) {
+		return Cheer{}, false
+	}
+	amount, err := strconv.Atoi(word[i:])
+	if err != nil || amount <= 0 {
+		return Cheer{}, false
+	}
+	prefix := word[:i]
+	c := getCheermote(prefix)
+	if c == nil && !strings.EqualFold(prefix, "cheer") {
+		return Cheer{}, false
+	}
+	cheer := Cheer{Prefix: prefix, Amount: amount}
+	if c != nil {
+		cheer.Tier = c.Tier(amount)
+	}
+	return cheer, true
+}
+
+// Cheers returns cheermote tokens of message, empty if message has no bits
+func (m *Message) Cheers() []Cheer {
+	if m.Bits <= 0 {
+		return nil
+	}
+	var cheers []Cheer
+	for _, word := range strings.Fields(m.Text) {
+		if c, ok := parseCheer(word); ok {
+			cheers = append(cheers, c)
+		}
+	}
+	return cheers
+}
+
+// renderText escapes text and renders cheermotes if message has bits
+func (m *Message) renderText(text string) string {
+	if m.Bits <= 0 {
+		return html.EscapeString(text)
+	}
+	words := strings.Split(text, " ")
+	for i, word := range words {
+		c, ok := parseCheer(word)
+		if !ok {
+			words[i] = html.EscapeString(word)
+			continue
+		}
+		words[i] = renderCheer(c, word)
+	}
+	return strings.Join(words, " ")
+}
+
+func renderCheer(c Cheer, word string) string {
+	if c.Tier == nil {
+		return fmt.Sprintf(`<span class="cheer twitch-cheer">%s</span>`, html.EscapeString(word))
+	}
+	img := ""
+	if url := c.Tier.Image("1"); url != "" {
+		img = `<img class="cheermote twitch-cheermote" src="` + url + `" alt="` + html.EscapeString(c.Prefix) + `">`
+	}
+	return fmt.Sprintf(`<span class="cheer twitch-cheer">%s<span class="cheer-amount" style="color: %s">%d</span></span>`,
+		img, html.EscapeString(c.Tier.Color), c.Amount)
+}
+
+func requestAndParseCheermotes() error {
+	if CheermotesURL == "" {
+		return nil
+	}
+	req, err := http.NewRequest("GET", CheermotesURL, nil)
+	if err != nil {
+		return err
+	}
+	// token of helix is used first, CheermotesToken is for bots without helix
+	if h := getHelix(); h != nil {
+		token, err := h.accessToken()
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Client-ID", h.clientID)
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		if clientID != "" {
+			req.Header.Set("Client-ID", clientID)
+		}
+		if CheermotesToken != "" {
+			req.Header.Set("Authorization", "Bearer "+CheermotesToken)
+		}
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return errors.New("cheermotes: " + string(b))
+	}
+	var resp struct {
+		Data []Cheermote `json:"data"`
+	}
+	err = json.Unmarshal(b, &resp)
+	if err != nil {
+		return err
+	}
+	SetCheermotes(resp.Data)
+	return nil
+}
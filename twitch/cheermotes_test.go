@@ -0,0 +1,47 @@
+package twitch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestCheermotesHeaders(t *testing.T) {
+	var headers http.Header
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer s.Close()
+	url, token, id := CheermotesURL, CheermotesToken, clientID
+	CheermotesURL, CheermotesToken, clientID = s.URL, "cheer-token", "render-id"
+	defer func() {
+		CheermotesURL, CheermotesToken, clientID = url, token, id
+		helixClient = nil
+		SetCheermotes(nil)
+	}()
+
+	tests := []struct {
+		name     string
+		helix    *Helix
+		clientID string
+		auth     string
+	}{
+		{"without helix", nil, "render-id", "Bearer cheer-token"},
+		{"helix first", NewHelix("helix-id", "helix-token"), "helix-id", "Bearer helix-token"},
+	}
+	for _, tt := range tests {
+		helixLocker.Lock()
+		helixClient = tt.helix
+		helixLocker.Unlock()
+		if err := requestAndParseCheermotes(); err != nil {
+			t.Fatal(err)
+		}
+		if got := headers["Client-Id"]; len(got) != 1 || got[0] != tt.clientID {
+			t.Errorf("%q. Client-ID = %v, want %v", tt.name, got, tt.clientID)
+		}
+		if got := headers["Authorization"]; len(got) != 1 || got[0] != tt.auth {
+			t.Errorf("%q. Authorization = %v, want %v", tt.name, got, tt.auth)
+		}
+	}
+}
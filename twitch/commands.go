@@ -0,0 +1,116 @@
+package twitch
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/FireGM/chats/interfaces"
+)
+
+// Notice is NOTICE from server, MsgID is value of msg-id tag
+type Notice struct {
+	Message
+	MsgID string `json:"msg_id"`
+}
+
+// ClearMsg is deletion of single message
+type ClearMsg struct {
+	Message
+	Login       string `json:"login"`
+	TargetMsgID string `json:"target_msg_id"`
+}
+
+// DeletedMessageID is id of deleted message
+func (c *ClearMsg) DeletedMessageID() string {
+	return c.TargetMsgID
+}
+
+// Whisper is private message to bot, it is passed only to callback of OnWhisper
+type Whisper struct {
+	Message
+	To        string `json:"to"`
+	MessageID string `json:"message_id"`
+	ThreadID  string `json:"thread_id"`
+}
+
+// IsPrivate is true, whisper is not visible in channel
+func (w *Whisper) IsPrivate() bool {
+	return true
+}
+
+// HostTarget is start or stop of hosting, Target is "-" when hosting stopped
+type HostTarget struct {
+	Message
+	Target  string `json:"target"`
+	Viewers int    `json:"viewers"`
+}
+
+// Reconnect is request of server to reconnect
+type Reconnect struct {
+	Message
+}
+
+// GlobalUserState is sent after login
+type GlobalUserState struct {
+	Message
+	UserID    string   `json:"user_id"`
+	EmoteSets []string `json:"emote_sets"`
+}
+
+// Numeric is numeric reply of server like 001 or 353
+type Numeric struct {
+	Message
+	Code   int      `json:"code"`
+	Params []string `json:"params"`
+}
+
+// Parse parses line of chat to typed message: *Message for PRIVMSG,
+// CLEARCHAT, USERSTATE, ROOMSTATE, JOIN, PART, PING, PONG and CAP, UserNoticeEvent for USERNOTICE
+// and *Notice, *ClearMsg, *Whisper, *HostTarget, *Reconnect, *GlobalUserState, *Numeric for others
+func Parse(line string) (interfaces.Message, error) {
+	m, irc, err := parseLine(line)
+	if err != nil {
+		return nil, err
+	}
+	return typedMessage(m, irc), nil
+}
+
+func typedMessage(m Message, irc *IRCMessage) interfaces.Message {
+	switch m.Type {
+	case usernoticeMsg:
+		return parseUserNotice(m)
+	case noticeMsg:
+		return &Notice{Message: m, MsgID: m.Tags["msg-id"]}
+	case clearSingleMsg:
+		m.User = m.Tags["login"]
+		return &ClearMsg{Message: m, Login: m.Tags["login"], TargetMsgID: m.Tags["target-msg-id"]}
+	case whisperMsg:
+		w := &Whisper{Message: m, MessageID: m.Tags["message-id"], ThreadID: m.Tags["thread-id"]}
+		if len(irc.Params) > 0 {
+			w.To = irc.Params[0]
+		}
+		return w
+	case hostTargetMsg:
+		h := &HostTarget{Message: m}
+		fields := strings.Fields(m.Text)
+		if len(fields) > 0 {
+			h.Target = fields[0]
+		}
+		if len(fields) > 1 {
+			h.Viewers, _ = strconv.Atoi(fields[1])
+		}
+		return h
+	case reconnectMsg:
+		return &Reconnect{Message: m}
+	case globalUserStateMsg:
+		g := &GlobalUserState{Message: m, UserID: m.Tags["user-id"]}
+		if sets := m.Tags["emote-sets"]; sets != "" {
+			g.EmoteSets = strings.Split(sets, ",")
+		}
+		return g
+	}
+	if code, err := strconv.Atoi(m.Type); err == nil && isDigits(m.Type) {
+		return &Numeric{Message: m, Code: code, Params: irc.Params}
+	}
+	return &m
+}
@@ -0,0 +1,245 @@
+package twitch
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/FireGM/chats/interfaces"
+)
+
+const defaultPingInterval = time.Minute
+const defaultPongTimeout = 10 * time.Second
+
+// migrateOverlap is how long old connection is read after RECONNECT
+var migrateOverlap = 2 * time.Second
+
+// loginTimeout is wait of welcome after login
+var loginTimeout = 10 * time.Second
+
+var errNotConnected = errors.New("not connected")
+var errLoginTimeout = errors.New("twitch: no answer on login")
+
+// ircConn is one connection to irc, every connection has own reader and keepalive
+type ircConn struct {
+	net.Conn
+	lastRead    int64
+	done        chan struct{}
+	closeOnce   sync.Once
+	login       chan error
+	loginOnce   sync.Once
+	writeLocker sync.Mutex
+}
+
+func newIRCConn(c net.Conn) *ircConn {
+	return &ircConn{Conn: c, lastRead: time.Now().UnixNano(), done: make(chan struct{}),
+		login: make(chan error, 1)}
+}
+
+func (c *ircConn) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+	return c.Conn.Close()
+}
+
+// finishLogin reports result of login once, it returns false if login was not finished before
+func (c *ircConn) finishLogin(err error) bool {
+	first := false
+	c.loginOnce.Do(func() {
+		first = true
+		c.login <- err
+	})
+	return !first
+}
+
+func (c *ircConn) writeLine(line string) error {
+	c.writeLocker.Lock()
+	defer c.writeLocker.Unlock()
+	_, err := fmt.Fprint(c.Conn, line+"\r\n")
+	return err
+}
+
+func (c *ircConn) touch() {
+	atomic.StoreInt64(&c.lastRead, time.Now().UnixNano())
+}
+
+func (c *ircConn) lastReadTime() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&c.lastRead))
+}
+
+// SetKeepalive sets interval of client PINGs and timeout of answer,
+// connection without any line from server during timeout is reconnected
+func (b *Bot) SetKeepalive(interval, timeout time.Duration) {
+	b.pingInterval = interval
+	b.pongTimeout = timeout
+}
+
+func (b *Bot) keepalive(c *ircConn) {
+	interval, timeout := b.pingInterval, b.pongTimeout
+	if interval == 0 {
+		interval = defaultPingInterval
+	}
+	if timeout == 0 {
+		timeout = defaultPongTimeout
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.done:
+			return
+		}
+		sent := time.Now()
+		if err := c.writeLine("PING :tmi.twitch.tv"); err != nil {
+			c.Close()
+			return
+		}
+		select {
+		case <-time.After(timeout):
+		case <-c.done:
+			return
+		}
+		if c.lastReadTime().Before(sent) {
+			log.Println("twitch: no answer on ping, connection is dead")
+			c.Close()
+			return
+		}
+	}
+}
+
+func (b *Bot) currentConn() *ircConn {
+	b.connLocker.RLock()
+	defer b.connLocker.RUnlock()
+	return b.conn
+}
+
+// switchConn opens new connection, makes it current after login and joins channels again.
+// Rejected login is *AuthError
+func (b *Bot) switchConn(token string) (*ircConn, error) {
+	nc, err := b.dial()
+	if err != nil {
+		return nil, err
+	}
+	c := newIRCConn(nc)
+	go b.read(c)
+	b.login(c, token)
+	select {
+	case err = <-c.login:
+	case <-time.After(loginTimeout):
+		err = errLoginTimeout
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	b.connLocker.Lock()
+	b.conn = c
+	b.connLocker.Unlock()
+	go b.keepalive(c)
+	b.rejoin(c)
+	return c, nil
+}
+
+// rejoin joins channels on new connection, they are joining until confirmed again
+func (b *Bot) rejoin(c *ircConn) {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	for name, ch := range b.channels {
+		switch ch.info.State {
+		case ChannelParting, ChannelFailed:
+			delete(b.channels, name)
+			continue
+		}
+		if ch.info.State == ChannelJoined {
+			ch.info.State = ChannelJoining
+			ch.done = make(chan struct{})
+		}
+		c.writeLine("JOIN #" + name)
+	}
+}
+
+// reconnectWait is first pause between failed reconnects, it doubles up to 30 seconds
+var reconnectWait = time.Second
+
+// reconnect retries until bot is connected or disconnected by user.
+// It stops on rejected token which can't be refreshed
+func (b *Bot) reconnect() {
+	wait := reconnectWait
+	for !b.isDisconnected() {
+		retry, err := b.openConn()
+		if err == nil {
+			return
+		}
+		log.Println(err)
+		if !retry {
+			return
+		}
+		time.Sleep(wait)
+		if wait < 30*time.Second {
+			wait *= 2
+		}
+	}
+}
+
+// migrate moves bot to new connection on RECONNECT, old connection is read
+// during migrateOverlap so messages are not lost, duplicates are skipped by id
+func (b *Bot) migrate(old *ircConn) {
+	if b.currentConn() != old || b.isDisconnected() {
+		return
+	}
+	token, err := b.accessToken()
+	if err == nil {
+		_, err = b.switchConn(token)
+	}
+	if err != nil {
+		// old connection is reconnected when server drops it
+		log.Println(err)
+		return
+	}
+	select {
+	case <-time.After(migrateOverlap):
+	case <-old.done:
+	}
+	old.Close()
+}
+
+// dedupe remembers last ids of messages
+type dedupe struct {
+	seen   map[string]bool
+	ring   []string
+	next   int
+	locker sync.Mutex
+}
+
+const dedupeSize = 1024
+
+// add returns false if id was seen
+func (d *dedupe) add(id string) bool {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	if d.seen == nil {
+		d.seen = map[string]bool{}
+		d.ring = make([]string, dedupeSize)
+	}
+	if d.seen[id] {
+		return false
+	}
+	delete(d.seen, d.ring[d.next])
+	d.ring[d.next] = id
+	d.next = (d.next + 1) % len(d.ring)
+	d.seen[id] = true
+	return true
+}
+
+func messageID(m interfaces.Message) string {
+	if i, ok := m.(interface {
+		GetMessageID() string
+	}); ok {
+		return i.GetMessageID()
+	}
+	return ""
+}
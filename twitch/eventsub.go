@@ -0,0 +1,527 @@
+package twitch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/FireGM/chats/dispatch"
+	"github.com/FireGM/chats/interfaces"
+	"github.com/gorilla/websocket"
+)
+
+// EventSubURL is websocket of eventsub, can be changed for fake servers
+var EventSubURL = "wss://eventsub.wss.twitch.tv/ws"
+
+// welcomeTimeout is wait of session_welcome after connect
+var welcomeTimeout = 10 * time.Second
+
+var errNoWelcome = errors.New("eventsub: no session_welcome")
+
+// subscription types of eventsub
+const (
+	SubFollow          = "channel.follow"
+	SubRedemption      = "channel.channel_points_custom_reward_redemption.add"
+	SubStreamOnline    = "stream.online"
+	SubStreamOffline   = "stream.offline"
+	SubPollBegin       = "channel.poll.begin"
+	SubPollProgress    = "channel.poll.progress"
+	SubPollEnd         = "channel.poll.end"
+	SubPredictionBegin = "channel.prediction.begin"
+	SubPredictionLock  = "channel.prediction.lock"
+	SubPredictionEnd   = "channel.prediction.end"
+	SubHypeTrainBegin  = "channel.hype_train.begin"
+	SubHypeTrainEnd    = "channel.hype_train.end"
+)
+
+// NewEventSub creates eventsub websocket client, subscriptions are created by helix
+// with user token. Events are delivered to handle like messages of chat
+func NewEventSub(h *Helix, handle func(interfaces.Message, interfaces.Bot)) *EventSub {
+	return &EventSub{helix: h, handleFunc: handle}
+}
+
+type EventSub struct {
+	helix      *Helix
+	handleFunc func(interfaces.Message, interfaces.Bot)
+	bot        interfaces.Bot
+	dispatcher dispatch.Holder
+	seen       dedupe
+
+	onSubscribeError func(*SubscribeError, *EventSub)
+
+	subs       []EventSubCondition
+	conn       *websocket.Conn
+	sessionID  string
+	disconnect bool
+	locker     sync.RWMutex
+}
+
+// EventSubCondition is subscription: type, version and condition like broadcaster_user_id
+type EventSubCondition struct {
+	Type      string            `json:"type"`
+	Version   string            `json:"version"`
+	Condition map[string]string `json:"condition"`
+}
+
+// SubscribeError is subscriptions which were not created for new session, connection is kept open
+type SubscribeError struct {
+	Failed []EventSubCondition
+	Errs   []error
+}
+
+func (e *SubscribeError) Error() string {
+	var b bytes.Buffer
+	b.WriteString("eventsub: subscriptions are not created: ")
+	for i, sub := range e.Failed {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(sub.Type + ": " + e.Errs[i].Error())
+	}
+	return b.String()
+}
+
+// EventSubEvent is implemented by all events of eventsub
+type EventSubEvent interface {
+	interfaces.Message
+	Event() *ChannelEvent
+}
+
+// ChannelEvent is common part of events, type without own struct is delivered as is with Raw
+type ChannelEvent struct {
+	Message              `json:"-"`
+	SubscriptionID       string          `json:"-"`
+	SubscriptionType     string          `json:"-"`
+	BroadcasterUserID    string          `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string          `json:"broadcaster_user_login"`
+	BroadcasterUserName  string          `json:"broadcaster_user_name"`
+	Raw                  json.RawMessage `json:"-"`
+}
+
+func (e *ChannelEvent) Event() *ChannelEvent {
+	return e
+}
+
+// FollowEvent is channel.follow
+type FollowEvent struct {
+	ChannelEvent
+	UserID     string    `json:"user_id"`
+	UserLogin  string    `json:"user_login"`
+	UserName   string    `json:"user_name"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+// RedemptionEvent is redemption of channel points reward
+type RedemptionEvent struct {
+	ChannelEvent
+	ID        string `json:"id"`
+	UserID    string `json:"user_id"`
+	UserLogin string `json:"user_login"`
+	UserName  string `json:"user_name"`
+	UserInput string `json:"user_input"`
+	Status    string `json:"status"`
+	Reward    struct {
+		ID     string `json:"id"`
+		Title  string `json:"title"`
+		Cost   int    `json:"cost"`
+		Prompt string `json:"prompt"`
+	} `json:"reward"`
+	RedeemedAt time.Time `json:"redeemed_at"`
+}
+
+// StreamEvent is stream.online and stream.offline, offline has only broadcaster
+type StreamEvent struct {
+	ChannelEvent
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// IsOnline is true for stream.online
+func (e *StreamEvent) IsOnline() bool {
+	return e.SubscriptionType == SubStreamOnline
+}
+
+type PollChoice struct {
+	ID                 string `json:"id"`
+	Title              string `json:"title"`
+	Votes              int    `json:"votes"`
+	ChannelPointsVotes int    `json:"channel_points_votes"`
+}
+
+// PollEvent is begin, progress and end of poll
+type PollEvent struct {
+	ChannelEvent
+	ID        string       `json:"id"`
+	Title     string       `json:"title"`
+	Choices   []PollChoice `json:"choices"`
+	Status    string       `json:"status"`
+	StartedAt time.Time    `json:"started_at"`
+	EndsAt    time.Time    `json:"ends_at"`
+	EndedAt   time.Time    `json:"ended_at"`
+}
+
+type PredictionOutcome struct {
+	ID            string `json:"id"`
+	Title         string `json:"title"`
+	Color         string `json:"color"`
+	Users         int    `json:"users"`
+	ChannelPoints int    `json:"channel_points"`
+}
+
+// PredictionEvent is begin, lock and end of prediction
+type PredictionEvent struct {
+	ChannelEvent
+	ID               string              `json:"id"`
+	Title            string              `json:"title"`
+	Outcomes         []PredictionOutcome `json:"outcomes"`
+	WinningOutcomeID string              `json:"winning_outcome_id"`
+	Status           string              `json:"status"`
+	StartedAt        time.Time           `json:"started_at"`
+	LocksAt          time.Time           `json:"locks_at"`
+}
+
+// HypeTrainEvent is begin and end of hype train
+type HypeTrainEvent struct {
+	ChannelEvent
+	ID        string    `json:"id"`
+	Level     int       `json:"level"`
+	Total     int       `json:"total"`
+	Progress  int       `json:"progress"`
+	Goal      int       `json:"goal"`
+	StartedAt time.Time `json:"started_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	EndedAt   time.Time `json:"ended_at"`
+}
+
+type eventSubFrame struct {
+	Metadata struct {
+		MessageID        string `json:"message_id"`
+		MessageType      string `json:"message_type"`
+		SubscriptionType string `json:"subscription_type"`
+	} `json:"metadata"`
+	Payload struct {
+		Session struct {
+			ID                      string `json:"id"`
+			KeepaliveTimeoutSeconds int    `json:"keepalive_timeout_seconds"`
+			ReconnectURL            string `json:"reconnect_url"`
+		} `json:"session"`
+		Subscription struct {
+			ID     string `json:"id"`
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"subscription"`
+		Event json.RawMessage `json:"event"`
+	} `json:"payload"`
+}
+
+// SetBot sets bot which is passed to handler with events
+func (e *EventSub) SetBot(b interfaces.Bot) {
+	e.bot = b
+}
+
+// SetDispatcher replaces dispatcher of handler calls, it can be shared with bots
+// and is not closed by Disconnect
+func (e *EventSub) SetDispatcher(d *dispatch.Dispatcher) {
+	e.dispatcher.Set(d)
+}
+
+// Subscribe adds subscription, it is created now if client is connected
+// and again after every new session. Subscription rejected by helix,
+// like one with wrong condition or without scope, is not kept
+func (e *EventSub) Subscribe(typ, version string, condition map[string]string) error {
+	sub := EventSubCondition{Type: typ, Version: version, Condition: condition}
+	e.locker.RLock()
+	session := e.sessionID
+	e.locker.RUnlock()
+	var err error
+	if session != "" {
+		_, err = e.helix.CreateEventSubSubscription(sub, session)
+	}
+	if !rejectedSubscription(err) {
+		e.locker.Lock()
+		e.subs = append(e.subs, sub)
+		e.locker.Unlock()
+	}
+	return err
+}
+
+// rejectedSubscription is error which is repeated for every session, 429 and
+// errors of server or network are not
+func rejectedSubscription(err error) bool {
+	e, ok := err.(*HelixError)
+	return ok && e.Status >= 400 && e.Status < 500 && e.Status != http.StatusTooManyRequests
+}
+
+// forget removes rejected subscriptions, they are reported once
+func (e *EventSub) forget(rejected []EventSubCondition) {
+	if len(rejected) == 0 {
+		return
+	}
+	e.locker.Lock()
+	defer e.locker.Unlock()
+	var kept []EventSubCondition
+	for _, sub := range e.subs {
+		found := false
+		for _, r := range rejected {
+			if reflect.DeepEqual(sub, r) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			kept = append(kept, sub)
+		}
+	}
+	e.subs = kept
+}
+
+// SubscribeFollows needs moderator:read:followers scope of moderator
+func (e *EventSub) SubscribeFollows(broadcasterID, moderatorID string) error {
+	return e.Subscribe(SubFollow, "2", map[string]string{"broadcaster_user_id": broadcasterID,
+		"moderator_user_id": moderatorID})
+}
+
+// SubscribeRedemptions needs channel:read:redemptions scope of broadcaster
+func (e *EventSub) SubscribeRedemptions(broadcasterID string) error {
+	return e.Subscribe(SubRedemption, "1", map[string]string{"broadcaster_user_id": broadcasterID})
+}
+
+// SubscribeStream subscribes stream.online and stream.offline
+func (e *EventSub) SubscribeStream(broadcasterID string) error {
+	condition := map[string]string{"broadcaster_user_id": broadcasterID}
+	if err := e.Subscribe(SubStreamOnline, "1", condition); err != nil {
+		return err
+	}
+	return e.Subscribe(SubStreamOffline, "1", condition)
+}
+
+// SubscribePolls needs channel:read:polls scope of broadcaster
+func (e *EventSub) SubscribePolls(broadcasterID string) error {
+	condition := map[string]string{"broadcaster_user_id": broadcasterID}
+	for _, typ := range []string{SubPollBegin, SubPollProgress, SubPollEnd} {
+		if err := e.Subscribe(typ, "1", condition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SubscribePredictions needs channel:read:predictions scope of broadcaster
+func (e *EventSub) SubscribePredictions(broadcasterID string) error {
+	condition := map[string]string{"broadcaster_user_id": broadcasterID}
+	for _, typ := range []string{SubPredictionBegin, SubPredictionLock, SubPredictionEnd} {
+		if err := e.Subscribe(typ, "1", condition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SubscribeHypeTrain needs channel:read:hype_train scope of broadcaster
+func (e *EventSub) SubscribeHypeTrain(broadcasterID string) error {
+	condition := map[string]string{"broadcaster_user_id": broadcasterID}
+	if err := e.Subscribe(SubHypeTrainBegin, "1", condition); err != nil {
+		return err
+	}
+	return e.Subscribe(SubHypeTrainEnd, "1", condition)
+}
+
+// OnSubscribeError sets callback for subscriptions failed after reconnect with new session
+func (e *EventSub) OnSubscribeError(f func(*SubscribeError, *EventSub)) {
+	e.onSubscribeError = f
+}
+
+// Connect opens session and creates subscriptions, *SubscribeError is returned
+// if some of them failed, events of others are delivered
+func (e *EventSub) Connect() error {
+	e.dispatcher.Open()
+	_, err := e.open(EventSubURL, true)
+	return err
+}
+
+func (e *EventSub) Disconnect() error {
+	e.locker.Lock()
+	e.disconnect = true
+	conn := e.conn
+	e.locker.Unlock()
+	e.dispatcher.Close()
+	if conn == nil {
+		return nil
+	}
+	return conn.Close()
+}
+
+func (e *EventSub) isDisconnected() bool {
+	e.locker.RLock()
+	defer e.locker.RUnlock()
+	return e.disconnect
+}
+
+func (e *EventSub) currentConn() *websocket.Conn {
+	e.locker.RLock()
+	defer e.locker.RUnlock()
+	return e.conn
+}
+
+// open connects to url and waits for welcome, subscriptions are created for new session
+func (e *EventSub) open(url string, subscribe bool) (*websocket.Conn, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetReadDeadline(time.Now().Add(welcomeTimeout))
+	var welcome eventSubFrame
+	if err := conn.ReadJSON(&welcome); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if welcome.Metadata.MessageType != "session_welcome" {
+		conn.Close()
+		return nil, errNoWelcome
+	}
+	session := welcome.Payload.Session
+	keepalive := time.Duration(session.KeepaliveTimeoutSeconds) * time.Second
+	if keepalive == 0 {
+		keepalive = welcomeTimeout
+	}
+	e.locker.Lock()
+	e.conn = conn
+	e.sessionID = session.ID
+	subs := append([]EventSubCondition(nil), e.subs...)
+	e.locker.Unlock()
+	go e.read(conn, keepalive)
+	if !subscribe {
+		return conn, nil
+	}
+	var failed SubscribeError
+	var rejected []EventSubCondition
+	for _, sub := range subs {
+		if _, err := e.helix.CreateEventSubSubscription(sub, session.ID); err != nil {
+			failed.Failed = append(failed.Failed, sub)
+			failed.Errs = append(failed.Errs, err)
+			if rejectedSubscription(err) {
+				rejected = append(rejected, sub)
+			}
+		}
+	}
+	e.forget(rejected)
+	if len(failed.Failed) > 0 {
+		return conn, &failed
+	}
+	return conn, nil
+}
+
+// read treats silence longer than keepalive timeout as dead connection
+func (e *EventSub) read(conn *websocket.Conn, keepalive time.Duration) {
+	for {
+		conn.SetReadDeadline(time.Now().Add(keepalive + 5*time.Second))
+		var frame eventSubFrame
+		_, data, err := conn.ReadMessage()
+		if err == nil {
+			err = json.Unmarshal(data, &frame)
+		}
+		if err != nil {
+			conn.Close()
+			if e.currentConn() != conn || e.isDisconnected() {
+				return
+			}
+			log.Println("eventsub:", err)
+			e.reconnect()
+			return
+		}
+		switch frame.Metadata.MessageType {
+		case "notification":
+			if !e.seen.add(frame.Metadata.MessageID) {
+				continue
+			}
+			ev := parseEvent(frame.Payload.Subscription.Type, frame.Payload.Subscription.ID, frame.Payload.Event)
+			e.dispatcher.Get().DispatchMessage(e.handleFunc, ev, e.bot)
+		case "session_reconnect":
+			go e.migrate(conn, frame.Payload.Session.ReconnectURL)
+		case "revocation":
+			log.Println("eventsub: subscription revoked", frame.Payload.Subscription.Type,
+				frame.Payload.Subscription.Status)
+		}
+	}
+}
+
+// migrate moves to reconnect url, subscriptions are kept by session
+func (e *EventSub) migrate(old *websocket.Conn, url string) {
+	if _, err := e.open(url, false); err != nil {
+		log.Println("eventsub:", err)
+		return
+	}
+	old.Close()
+}
+
+// reconnect opens new session and subscribes again
+func (e *EventSub) reconnect() {
+	wait := time.Second
+	for !e.isDisconnected() {
+		_, err := e.open(EventSubURL, true)
+		if se, ok := err.(*SubscribeError); ok {
+			if f := e.onSubscribeError; f != nil {
+				f(se, e)
+			} else {
+				log.Println(se)
+			}
+			return
+		}
+		if err == nil {
+			return
+		}
+		log.Println("eventsub:", err)
+		time.Sleep(wait)
+		if wait < 30*time.Second {
+			wait *= 2
+		}
+	}
+}
+
+func parseEvent(typ, id string, raw json.RawMessage) EventSubEvent {
+	var ev EventSubEvent
+	switch typ {
+	case SubFollow:
+		ev = &FollowEvent{}
+	case SubRedemption:
+		ev = &RedemptionEvent{}
+	case SubStreamOnline, SubStreamOffline:
+		ev = &StreamEvent{}
+	case SubPollBegin, SubPollProgress, SubPollEnd:
+		ev = &PollEvent{}
+	case SubPredictionBegin, SubPredictionLock, SubPredictionEnd, "channel.prediction.progress":
+		ev = &PredictionEvent{}
+	case SubHypeTrainBegin, SubHypeTrainEnd, "channel.hype_train.progress":
+		ev = &HypeTrainEvent{}
+	default:
+		ev = &ChannelEvent{}
+	}
+	if err := json.Unmarshal(raw, ev); err != nil {
+		log.Println("eventsub:", typ, err)
+	}
+	c := ev.Event()
+	c.SubscriptionType = typ
+	c.SubscriptionID = id
+	c.Raw = raw
+	c.Type = typ
+	c.Channel = c.BroadcasterUserLogin
+	c.RoomID, _ = strconv.Atoi(c.BroadcasterUserID)
+	switch ev := ev.(type) {
+	case *FollowEvent:
+		c.User, c.DisplayName = ev.UserLogin, ev.UserName
+	case *RedemptionEvent:
+		c.User, c.DisplayName, c.Text = ev.UserLogin, ev.UserName, ev.UserInput
+	case *PollEvent:
+		c.Text = ev.Title
+	case *PredictionEvent:
+		c.Text = ev.Title
+	}
+	return ev
+}
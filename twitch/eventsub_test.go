@@ -0,0 +1,177 @@
+package twitch_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FireGM/chats/chatstest"
+	"github.com/FireGM/chats/interfaces"
+	"github.com/FireGM/chats/twitch"
+)
+
+type eventLog struct {
+	events []twitch.EventSubEvent
+	locker sync.Mutex
+}
+
+func (l *eventLog) handle(m interfaces.Message, b interfaces.Bot) {
+	l.locker.Lock()
+	defer l.locker.Unlock()
+	l.events = append(l.events, m.(twitch.EventSubEvent))
+}
+
+func (l *eventLog) list() []twitch.EventSubEvent {
+	l.locker.Lock()
+	defer l.locker.Unlock()
+	return append([]twitch.EventSubEvent(nil), l.events...)
+}
+
+func TestEventSub(t *testing.T) {
+	hs, h, done := newHelix()
+	defer done()
+	s := chatstest.NewEventSubServer()
+	defer s.Close()
+	url := twitch.EventSubURL
+	twitch.EventSubURL = s.URL()
+	defer func() { twitch.EventSubURL = url }()
+
+	var l eventLog
+	e := twitch.NewEventSub(h, l.handle)
+	e.SubscribeFollows("1", "2")
+	e.SubscribeRedemptions("1")
+	if err := e.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer e.Disconnect()
+	subs := hs.Subscriptions()
+	if len(subs) != 2 || subs[0].Type != twitch.SubFollow || subs[0].SessionID != "session-1" ||
+		subs[0].Condition["moderator_user_id"] != "2" {
+		t.Fatalf("Subscriptions() = %+v", subs)
+	}
+	e.SubscribeStream("1")
+	if got := len(hs.Subscriptions()); got != 4 {
+		t.Errorf("subscriptions = %v, want %v", got, 4)
+	}
+
+	id := s.Notify(twitch.SubFollow, map[string]string{"broadcaster_user_id": "1", "broadcaster_user_login": "chan",
+		"user_id": "3", "user_login": "fan", "user_name": "Fan", "followed_at": "2023-07-15T18:16:11Z"})
+	s.NotifyWithID(id, twitch.SubFollow, map[string]string{"broadcaster_user_id": "1"})
+	s.Notify(twitch.SubRedemption, map[string]interface{}{"broadcaster_user_id": "1", "broadcaster_user_login": "chan",
+		"user_login": "fan", "user_input": "hello", "reward": map[string]interface{}{"title": "Hydrate", "cost": 100}})
+	waitUntil(t, "events", func() bool { return len(l.list()) == 2 })
+
+	follow, ok := l.list()[0].(*twitch.FollowEvent)
+	if !ok {
+		t.Fatalf("event = %T, want *twitch.FollowEvent", l.list()[0])
+	}
+	if follow.GetChannelName() != "chan" || follow.GetUserFrom() != "fan" || follow.RoomID != 1 ||
+		follow.FollowedAt.Year() != 2023 {
+		t.Errorf("follow = %+v", follow)
+	}
+	r, ok := l.list()[1].(*twitch.RedemptionEvent)
+	if !ok {
+		t.Fatalf("event = %T, want *twitch.RedemptionEvent", l.list()[1])
+	}
+	if r.Reward.Title != "Hydrate" || r.Reward.Cost != 100 || r.GetTextMessage() != "hello" {
+		t.Errorf("redemption = %+v", r)
+	}
+}
+
+func TestEventSubReconnect(t *testing.T) {
+	hs, h, done := newHelix()
+	defer done()
+	s := chatstest.NewEventSubServer()
+	defer s.Close()
+	url := twitch.EventSubURL
+	twitch.EventSubURL = s.URL()
+	defer func() { twitch.EventSubURL = url }()
+
+	var l eventLog
+	e := twitch.NewEventSub(h, l.handle)
+	e.SubscribeStream("1")
+	if err := e.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer e.Disconnect()
+
+	// reconnect url keeps session and subscriptions
+	s.Reconnect()
+	waitUntil(t, "migration", func() bool {
+		sessions := s.Sessions()
+		return len(sessions) == 1 && sessions[0] == "session-1"
+	})
+	if got := len(hs.Subscriptions()); got != 2 {
+		t.Errorf("subscriptions after reconnect = %v, want %v", got, 2)
+	}
+	s.Notify(twitch.SubStreamOnline, map[string]string{"broadcaster_user_login": "chan", "type": "live"})
+	waitUntil(t, "online", func() bool { return len(l.list()) == 1 })
+	if ev, ok := l.list()[0].(*twitch.StreamEvent); !ok || !ev.IsOnline() || ev.Type != "live" {
+		t.Errorf("event = %+v, want online stream", l.list()[0])
+	}
+
+	// lost connection gets new session which is subscribed again
+	s.DisconnectAll()
+	waitUntil(t, "new session", func() bool {
+		sessions := s.Sessions()
+		return len(sessions) == 1 && sessions[0] == "session-2"
+	})
+	waitUntil(t, "resubscribe", func() bool { return len(hs.Subscriptions()) == 4 })
+	if sub := hs.Subscriptions()[3]; sub.SessionID != "session-2" {
+		t.Errorf("SessionID = %v, want %v", sub.SessionID, "session-2")
+	}
+}
+
+func TestEventSubSubscribeError(t *testing.T) {
+	hs, h, done := newHelix()
+	defer done()
+	s := chatstest.NewEventSubServer()
+	defer s.Close()
+	url := twitch.EventSubURL
+	twitch.EventSubURL = s.URL()
+	defer func() { twitch.EventSubURL = url }()
+
+	var l eventLog
+	e := twitch.NewEventSub(h, l.handle)
+	e.SubscribeStream("1")
+	e.Subscribe("", "1", map[string]string{"broadcaster_user_id": "1"})
+	failed := make(chan *twitch.SubscribeError, 1)
+	e.OnSubscribeError(func(err *twitch.SubscribeError, _ *twitch.EventSub) { failed <- err })
+	err := e.Connect()
+	defer e.Disconnect()
+	if se, ok := err.(*twitch.SubscribeError); !ok || len(se.Failed) != 1 || len(se.Errs) != 1 {
+		t.Fatalf("Connect() = %v, want SubscribeError with one subscription", err)
+	}
+	if got := len(hs.Subscriptions()); got != 2 {
+		t.Errorf("subscriptions = %v, want %v", got, 2)
+	}
+	s.Notify(twitch.SubStreamOnline, map[string]string{"broadcaster_user_login": "chan", "type": "live"})
+	waitUntil(t, "online", func() bool { return len(l.list()) == 1 })
+
+	// rejected subscription is dropped, it fails for connected client too
+	if err := e.Subscribe("", "1", map[string]string{"broadcaster_user_id": "1"}); err == nil {
+		t.Error("Subscribe() of invalid subscription = nil, want error")
+	}
+
+	// new session after lost connection reports only temporary failures to callback
+	hs.RateLimit(4)
+	s.DisconnectAll()
+	select {
+	case err := <-failed:
+		if len(err.Failed) != 1 || err.Failed[0].Type != twitch.SubStreamOnline {
+			t.Errorf("Failed = %+v, want rate limited %v", err.Failed, twitch.SubStreamOnline)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnSubscribeError is not called after reconnect")
+	}
+	invalid := 0
+	for _, r := range hs.Received() {
+		if r.Method == "POST" && strings.Contains(r.Body, `"type":""`) {
+			invalid++
+		}
+	}
+	if invalid != 2 {
+		t.Errorf("requests of invalid subscription = %v, want %v", invalid, 2)
+	}
+}
@@ -0,0 +1,15 @@
+package twitch
+
+import "time"
+
+// failed reconnects are retried quickly in tests
+func init() {
+	reconnectWait = 10 * time.Millisecond
+}
+
+// SetLoginTimeout changes wait of welcome for tests of package twitch_test, it returns restore function
+func SetLoginTimeout(d time.Duration) func() {
+	old := loginTimeout
+	loginTimeout = d
+	return func() { loginTimeout = old }
+}
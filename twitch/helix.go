@@ -0,0 +1,403 @@
+package twitch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// HelixURL is base of helix api, can be changed for fake servers
+var HelixURL = "https://api.twitch.tv/helix"
+
+// helixMaxIDs is max count of ids or logins in one request
+const helixMaxIDs = 100
+
+// NewHelix creates helix api client, token is user or app access token without "oauth:"
+func NewHelix(clientID, token string) *Helix {
+	return &Helix{clientID: clientID, token: token, client: &http.Client{Timeout: time.Second * 20}}
+}
+
+// Helix is client of twitch helix api. It waits when rate limit is exhausted
+type Helix struct {
+	clientID string
+	token    string
+	source   TokenSource
+	client   *http.Client
+	locker   sync.RWMutex
+
+	remaining   int
+	reset       time.Time
+	limitLocker sync.Mutex
+}
+
+// HelixError is error response of helix
+type HelixError struct {
+	Status  int    `json:"status"`
+	Err     string `json:"error"`
+	Message string `json:"message"`
+}
+
+func (e *HelixError) Error() string {
+	return fmt.Sprintf("helix: %d %s: %s", e.Status, e.Err, e.Message)
+}
+
+// HelixUser is user from /users
+type HelixUser struct {
+	ID              string `json:"id"`
+	Login           string `json:"login"`
+	DisplayName     string `json:"display_name"`
+	Type            string `json:"type"`
+	BroadcasterType string `json:"broadcaster_type"`
+	Description     string `json:"description"`
+	ProfileImageURL string `json:"profile_image_url"`
+}
+
+// HelixBadgeSet is set of chat badge versions
+type HelixBadgeSet struct {
+	SetID    string              `json:"set_id"`
+	Versions []HelixBadgeVersion `json:"versions"`
+}
+
+type HelixBadgeVersion struct {
+	ID string `json:"id"`
+	Badge
+}
+
+// ChatSettings of channel, nil fields are not changed by UpdateChatSettings
+type ChatSettings struct {
+	BroadcasterID                 string `json:"broadcaster_id,omitempty"`
+	EmoteMode                     *bool  `json:"emote_mode,omitempty"`
+	FollowerMode                  *bool  `json:"follower_mode,omitempty"`
+	FollowerModeDuration          *int   `json:"follower_mode_duration,omitempty"`
+	NonModeratorChatDelay         *bool  `json:"non_moderator_chat_delay,omitempty"`
+	NonModeratorChatDelayDuration *int   `json:"non_moderator_chat_delay_duration,omitempty"`
+	SlowMode                      *bool  `json:"slow_mode,omitempty"`
+	SlowModeWaitTime              *int   `json:"slow_mode_wait_time,omitempty"`
+	SubscriberMode                *bool  `json:"subscriber_mode,omitempty"`
+	UniqueChatMode                *bool  `json:"unique_chat_mode,omitempty"`
+}
+
+// Bool is helper for fields of ChatSettings
+func Bool(v bool) *bool {
+	return &v
+}
+
+// Int is helper for fields of ChatSettings
+func Int(v int) *int {
+	return &v
+}
+
+// SetToken replaces access token, for example after refresh
+func (h *Helix) SetToken(token string) {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+	h.token = token
+}
+
+// SetTokenSource makes client get token from source, token is refreshed on 401
+func (h *Helix) SetTokenSource(ts TokenSource) {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+	h.source = ts
+}
+
+func (h *Helix) accessToken() (string, error) {
+	h.locker.RLock()
+	token, source := h.token, h.source
+	h.locker.RUnlock()
+	if source == nil {
+		return token, nil
+	}
+	t, err := source.Token()
+	return t.AccessToken, err
+}
+
+// invalidate makes source refresh token, false if token can't be refreshed
+func (h *Helix) invalidate() bool {
+	h.locker.RLock()
+	defer h.locker.RUnlock()
+	if i, ok := h.source.(interface {
+		Invalidate()
+	}); ok {
+		i.Invalidate()
+		return true
+	}
+	return false
+}
+
+// Users returns users by logins, more than 100 logins are requested by parts
+func (h *Helix) Users(logins ...string) ([]HelixUser, error) {
+	return h.users("login", logins)
+}
+
+// UsersByID returns users by ids
+func (h *Helix) UsersByID(ids ...string) ([]HelixUser, error) {
+	return h.users("id", ids)
+}
+
+func (h *Helix) users(key string, values []string) ([]HelixUser, error) {
+	var users []HelixUser
+	for len(values) > 0 {
+		n := len(values)
+		if n > helixMaxIDs {
+			n = helixMaxIDs
+		}
+		query := url.Values{key: values[:n]}
+		values = values[n:]
+		var resp struct {
+			Data []HelixUser `json:"data"`
+		}
+		if err := h.do("GET", "/users", query, nil, &resp); err != nil {
+			return users, err
+		}
+		users = append(users, resp.Data...)
+	}
+	return users, nil
+}
+
+// GlobalBadges returns global chat badges
+func (h *Helix) GlobalBadges() ([]HelixBadgeSet, error) {
+	var resp struct {
+		Data []HelixBadgeSet `json:"data"`
+	}
+	err := h.do("GET", "/chat/badges/global", nil, nil, &resp)
+	return resp.Data, err
+}
+
+// ChannelBadges returns subscriber and bits badges of channel
+func (h *Helix) ChannelBadges(broadcasterID string) ([]HelixBadgeSet, error) {
+	var resp struct {
+		Data []HelixBadgeSet `json:"data"`
+	}
+	err := h.do("GET", "/chat/badges", url.Values{"broadcaster_id": {broadcasterID}}, nil, &resp)
+	return resp.Data, err
+}
+
+// Ban bans user, duration in seconds makes timeout, 0 is permanent ban
+func (h *Helix) Ban(broadcasterID, moderatorID, userID string, duration int, reason string) error {
+	var body struct {
+		Data struct {
+			UserID   string `json:"user_id"`
+			Duration int    `json:"duration,omitempty"`
+			Reason   string `json:"reason,omitempty"`
+		} `json:"data"`
+	}
+	body.Data.UserID = userID
+	body.Data.Duration = duration
+	body.Data.Reason = reason
+	query := url.Values{"broadcaster_id": {broadcasterID}, "moderator_id": {moderatorID}}
+	return h.do("POST", "/moderation/bans", query, body, nil)
+}
+
+// Unban removes ban or timeout of user
+func (h *Helix) Unban(broadcasterID, moderatorID, userID string) error {
+	query := url.Values{"broadcaster_id": {broadcasterID}, "moderator_id": {moderatorID}, "user_id": {userID}}
+	return h.do("DELETE", "/moderation/bans", query, nil, nil)
+}
+
+// DeleteMessage deletes message by id, helix clears all chat without id,
+// so empty id is error, see ClearChat
+func (h *Helix) DeleteMessage(broadcasterID, moderatorID, messageID string) error {
+	if messageID == "" {
+		return errNoMessageID
+	}
+	query := url.Values{"broadcaster_id": {broadcasterID}, "moderator_id": {moderatorID},
+		"message_id": {messageID}}
+	return h.do("DELETE", "/moderation/chat", query, nil, nil)
+}
+
+// ClearChat deletes all messages of channel
+func (h *Helix) ClearChat(broadcasterID, moderatorID string) error {
+	query := url.Values{"broadcaster_id": {broadcasterID}, "moderator_id": {moderatorID}}
+	return h.do("DELETE", "/moderation/chat", query, nil, nil)
+}
+
+// SendWhisper sends private message, sender must be user of token with verified phone number
+func (h *Helix) SendWhisper(fromUserID, toUserID, message string) error {
+	body := map[string]string{"message": message}
+	query := url.Values{"from_user_id": {fromUserID}, "to_user_id": {toUserID}}
+	return h.do("POST", "/whispers", query, body, nil)
+}
+
+// ChatSettings returns chat modes of channel
+func (h *Helix) ChatSettings(broadcasterID string) (ChatSettings, error) {
+	var resp struct {
+		Data []ChatSettings `json:"data"`
+	}
+	err := h.do("GET", "/chat/settings", url.Values{"broadcaster_id": {broadcasterID}}, nil, &resp)
+	if err != nil || len(resp.Data) == 0 {
+		return ChatSettings{}, err
+	}
+	return resp.Data[0], nil
+}
+
+// UpdateChatSettings changes not nil fields of settings
+func (h *Helix) UpdateChatSettings(broadcasterID, moderatorID string, settings ChatSettings) error {
+	settings.BroadcasterID = ""
+	query := url.Values{"broadcaster_id": {broadcasterID}, "moderator_id": {moderatorID}}
+	return h.do("PATCH", "/chat/settings", query, settings, nil)
+}
+
+// GetAll requests every page of path, f gets data of each page
+func (h *Helix) GetAll(path string, query url.Values, f func(data json.RawMessage) error) error {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	for {
+		var page struct {
+			Data       json.RawMessage `json:"data"`
+			Pagination struct {
+				Cursor string `json:"cursor"`
+			} `json:"pagination"`
+		}
+		if err := h.do("GET", path, q, nil, &page); err != nil {
+			return err
+		}
+		if err := f(page.Data); err != nil {
+			return err
+		}
+		if page.Pagination.Cursor == "" {
+			return nil
+		}
+		q.Set("after", page.Pagination.Cursor)
+	}
+}
+
+// do sends request, out is decoded from json body if not nil.
+// Request which got 429 is repeated after reset of rate limit
+func (h *Helix) do(method, path string, query url.Values, body, out interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+	for try := 0; ; try++ {
+		h.waitLimit()
+		var r io.Reader
+		if data != nil {
+			r = bytes.NewReader(data)
+		}
+		req, err := http.NewRequest(method, HelixURL+path, r)
+		if err != nil {
+			return err
+		}
+		if len(query) > 0 {
+			req.URL.RawQuery = query.Encode()
+		}
+		token, err := h.accessToken()
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Client-Id", h.clientID)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if data != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		res, err := h.client.Do(req)
+		if err != nil {
+			return err
+		}
+		h.updateLimit(res.Header)
+		b, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return err
+		}
+		if res.StatusCode == http.StatusTooManyRequests && try < 3 {
+			h.backoff(try)
+			continue
+		}
+		if res.StatusCode == http.StatusUnauthorized && try == 0 && h.invalidate() {
+			continue
+		}
+		if res.StatusCode < 200 || res.StatusCode > 299 {
+			e := &HelixError{Status: res.StatusCode}
+			if json.Unmarshal(b, e) != nil || e.Message == "" {
+				e.Message = string(b)
+			}
+			return e
+		}
+		if out == nil || len(b) == 0 {
+			return nil
+		}
+		return json.Unmarshal(b, out)
+	}
+}
+
+// waitLimit sleeps until reset when no points left
+func (h *Helix) waitLimit() {
+	h.limitLocker.Lock()
+	wait := time.Duration(0)
+	if h.remaining <= 0 && !h.reset.IsZero() {
+		wait = h.reset.Sub(time.Now())
+	}
+	h.limitLocker.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// helixRetryWait is first wait after 429 when reset of limit is unknown, it doubles on each try
+var helixRetryWait = 250 * time.Millisecond
+
+// backoff sleeps after 429 until reset of limit, but at least helixRetryWait
+func (h *Helix) backoff(try int) {
+	wait := helixRetryWait << uint(try)
+	h.limitLocker.Lock()
+	if d := h.reset.Sub(time.Now()); d > wait {
+		wait = d
+	}
+	h.limitLocker.Unlock()
+	time.Sleep(wait)
+}
+
+func (h *Helix) updateLimit(header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("Ratelimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, _ := strconv.ParseInt(header.Get("Ratelimit-Reset"), 10, 64)
+	h.limitLocker.Lock()
+	defer h.limitLocker.Unlock()
+	h.remaining = remaining
+	h.reset = time.Unix(reset, 0)
+}
+
+// CreateEventSubSubscription subscribes websocket session, it returns id of subscription
+func (h *Helix) CreateEventSubSubscription(sub EventSubCondition, sessionID string) (string, error) {
+	var body struct {
+		EventSubCondition
+		Transport struct {
+			Method    string `json:"method"`
+			SessionID string `json:"session_id"`
+		} `json:"transport"`
+	}
+	body.EventSubCondition = sub
+	body.Transport.Method = "websocket"
+	body.Transport.SessionID = sessionID
+	var resp struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := h.do("POST", "/eventsub/subscriptions", nil, body, &resp); err != nil || len(resp.Data) == 0 {
+		return "", err
+	}
+	return resp.Data[0].ID, nil
+}
+
+// DeleteEventSubSubscription removes subscription by id
+func (h *Helix) DeleteEventSubSubscription(id string) error {
+	return h.do("DELETE", "/eventsub/subscriptions", url.Values{"id": {id}}, nil, nil)
+}
@@ -0,0 +1,217 @@
+package twitch_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FireGM/chats/chatstest"
+	"github.com/FireGM/chats/twitch"
+)
+
+func newHelix() (*chatstest.HelixServer, *twitch.Helix, func()) {
+	s := chatstest.NewHelixServer()
+	s.ClientID = "client"
+	s.Token = "token"
+	url := twitch.HelixURL
+	twitch.HelixURL = s.URL()
+	return s, twitch.NewHelix("client", "token"), func() {
+		twitch.HelixURL = url
+		s.Close()
+	}
+}
+
+func TestHelixUsers(t *testing.T) {
+	s, h, done := newHelix()
+	defer done()
+	var logins []string
+	for i := 0; i < 150; i++ {
+		login := fmt.Sprintf("user%d", i)
+		s.AddUser(fmt.Sprint(i), login)
+		logins = append(logins, login)
+	}
+	users, err := h.Users(logins...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 150 || users[149].ID != "149" {
+		t.Errorf("Users() = %d users, want 150", len(users))
+	}
+	if got := len(s.Received()); got != 2 {
+		t.Errorf("requests = %v, want %v", got, 2)
+	}
+}
+
+func TestHelixGetAll(t *testing.T) {
+	s, h, done := newHelix()
+	defer done()
+	s.AddUser("1", "chan")
+	s.AddUser("2", "mod")
+	for i := 0; i < 45; i++ {
+		if err := h.Ban("1", "2", fmt.Sprintf("u%02d", i), 0, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []string
+	err := h.GetAll("/moderation/banned", map[string][]string{"broadcaster_id": {"1"}}, func(data json.RawMessage) error {
+		var page []struct {
+			UserID string `json:"user_id"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		for _, u := range page {
+			got = append(got, u.UserID)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 45 || got[0] != "u00" || got[44] != "u44" {
+		t.Errorf("GetAll() = %v", got)
+	}
+}
+
+func TestHelixErrors(t *testing.T) {
+	s, h, done := newHelix()
+	defer done()
+	s.AddUser("1", "chan")
+	s.RateLimit(2)
+	if _, err := h.Users("chan"); err != nil {
+		t.Errorf("Users() after 429 = %v, want nil", err)
+	}
+	h.SetToken("expired")
+	_, err := h.Users("chan")
+	if e, ok := err.(*twitch.HelixError); !ok || e.Status != 401 {
+		t.Errorf("Users() with bad token = %v, want HelixError 401", err)
+	}
+	h.SetToken("token")
+	if err := h.Unban("1", "1", "nobody"); err == nil {
+		t.Error("Unban() of not banned user = nil, want error")
+	}
+}
+
+func TestHelixBackoff(t *testing.T) {
+	var requests int
+	var locker sync.Mutex
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locker.Lock()
+		requests++
+		locker.Unlock()
+		// no Ratelimit headers
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer s.Close()
+	url := twitch.HelixURL
+	twitch.HelixURL = s.URL
+	defer func() { twitch.HelixURL = url }()
+
+	start := time.Now()
+	_, err := twitch.NewHelix("client", "token").Users("chan")
+	if e, ok := err.(*twitch.HelixError); !ok || e.Status != http.StatusTooManyRequests {
+		t.Errorf("Users() = %v, want HelixError 429", err)
+	}
+	locker.Lock()
+	defer locker.Unlock()
+	if requests != 4 {
+		t.Errorf("requests = %v, want %v", requests, 4)
+	}
+	if d := time.Since(start); d < 500*time.Millisecond {
+		t.Errorf("3 retries took %v, want backoff", d)
+	}
+}
+
+func TestBotHelixModeration(t *testing.T) {
+	hs, h, done := newHelix()
+	defer done()
+	defer twitch.SetHelix(nil)
+	hs.AddUser("1", "chan")
+	hs.AddUser("2", "bot")
+	hs.AddUser("3", "troll")
+	s, err := chatstest.NewTwitchServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	b := twitch.New("bot", "token", nil)
+	b.SetServer(s.Addr())
+	b.SetHelix(h)
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+	if err := b.JoinAndWait("chan", 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Timeout("chan", "troll", 600); err != nil {
+		t.Fatal(err)
+	}
+	if got := hs.Bans("1")["3"]; got != 600 {
+		t.Errorf("timeout duration = %v, want %v", got, 600)
+	}
+	if err := b.Ban("chan", "troll"); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := hs.Bans("1")["3"]; !ok || got != 0 {
+		t.Errorf("ban duration = %v, want permanent", got)
+	}
+	if err := b.Unban("chan", "troll"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.DeleteMessage("chan", "msg-1"); err != nil {
+		t.Fatal(err)
+	}
+	// message without id tag must not clear all chat
+	if err := b.DeleteMessage("chan", ""); err == nil {
+		t.Errorf("DeleteMessage() with empty id = nil, want error")
+	}
+	if err := h.DeleteMessage("1", "2", ""); err == nil {
+		t.Errorf("Helix.DeleteMessage() with empty id = nil, want error")
+	}
+	if got := hs.Deleted(); len(got) != 1 || got[0] != "msg-1" {
+		t.Errorf("Deleted() = %v, want [msg-1]", got)
+	}
+	if err := b.ClearChat("chan"); err != nil {
+		t.Fatal(err)
+	}
+	if got := hs.Deleted(); len(got) != 2 || got[1] != "" {
+		t.Errorf("Deleted() = %q, want clear of chat", got)
+	}
+	if err := b.SetSlowMode("chan", 10); err != nil {
+		t.Fatal(err)
+	}
+	if st := hs.Settings("1"); st.SlowMode == nil || !*st.SlowMode || *st.SlowModeWaitTime != 10 {
+		t.Errorf("Settings() = %+v, want slow mode 10", st)
+	}
+	if err := b.SendWhisper("Troll", "stop"); err != nil {
+		t.Fatal(err)
+	}
+	if got := hs.Whispers(); len(got) != 1 || got[0] != (chatstest.HelixWhisper{FromUserID: "2", ToUserID: "3", Message: "stop"}) {
+		t.Errorf("Whispers() = %+v", got)
+	}
+	if err := twitch.New("bot", "token", nil).SendWhisper("troll", "stop"); err != twitch.ErrNoHelix {
+		t.Errorf("SendWhisper() without helix = %v, want %v", err, twitch.ErrNoHelix)
+	}
+	for _, d := range []int{0, -1} {
+		if err := b.Timeout("chan", "troll", d); err == nil {
+			t.Errorf("Timeout(%v) = nil, want error", d)
+		}
+	}
+	if got, ok := hs.Bans("1")["3"]; ok {
+		t.Errorf("ban after Timeout(0) = %v, want none", got)
+	}
+	if ban := b.Ban("chan", "ghost"); ban == nil {
+		t.Error("Ban() of unknown user = nil, want error")
+	}
+	for _, line := range s.Received() {
+		if len(line) > 9 && line[:9] == "PRIVMSG #" {
+			t.Errorf("chat command sent with helix: %q", line)
+		}
+	}
+}
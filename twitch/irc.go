@@ -0,0 +1,194 @@
+package twitch
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+// IRCMessage is generic IRCv3 message:
+// [@tags] [:prefix] command [params] [:trailing]
+type IRCMessage struct {
+	Raw         string
+	Tags        map[string]string
+	Prefix      string
+	Name        string
+	User        string
+	Host        string
+	Command     string
+	Params      []string
+	Trailing    string
+	HasTrailing bool
+}
+
+var errEmptyLine = errors.New("empty irc line")
+var errNoCommand = errors.New("no command in irc line")
+
+// ParseIRC tokenizes line of irc, tag values are unescaped
+func ParseIRC(line string) (*IRCMessage, error) {
+	m := &IRCMessage{Raw: line, Tags: map[string]string{}}
+	line = strings.TrimRight(line, "\r\n")
+	if strings.TrimSpace(line) == "" {
+		return nil, errEmptyLine
+	}
+	if strings.HasPrefix(line, "@") {
+		i := strings.IndexByte(line, ' ')
+		if i < 0 {
+			return nil, errNoCommand
+		}
+		m.Tags = parseIRCTags(line[1:i])
+		line = line[i+1:]
+	}
+	line = strings.TrimLeft(line, " ")
+	if strings.HasPrefix(line, ":") {
+		i := strings.IndexByte(line, ' ')
+		if i < 0 {
+			return nil, errNoCommand
+		}
+		m.Prefix = line[1:i]
+		m.Name, m.User, m.Host = splitPrefix(m.Prefix)
+		line = strings.TrimLeft(line[i+1:], " ")
+	}
+	if i := strings.IndexByte(line, ' '); i >= 0 {
+		m.Command, line = line[:i], line[i+1:]
+	} else {
+		m.Command, line = line, ""
+	}
+	if !validCommand(m.Command) {
+		return nil, errNoCommand
+	}
+	for line != "" {
+		if strings.HasPrefix(line, ":") {
+			m.Trailing = line[1:]
+			m.HasTrailing = true
+			break
+		}
+		var param string
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			param, line = line[:i], line[i+1:]
+		} else {
+			param, line = line, ""
+		}
+		if param != "" {
+			m.Params = append(m.Params, param)
+		}
+	}
+	return m, nil
+}
+
+// Channel is first param which starts with #, without #
+func (m *IRCMessage) Channel() string {
+	for _, p := range m.Params {
+		if strings.HasPrefix(p, "#") {
+			return p[1:]
+		}
+	}
+	return ""
+}
+
+func validCommand(cmd string) bool {
+	if cmd == "" {
+		return false
+	}
+	if len(cmd) == 3 && isDigits(cmd) {
+		return true
+	}
+	for _, r := range cmd {
+		if (r < 'A' || r > 'Z') && (r < 'a' || r > 'z') {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// nick!user@host or server name
+func splitPrefix(prefix string) (name, user, host string) {
+	i := strings.IndexByte(prefix, '!')
+	if i < 0 {
+		if j := strings.IndexByte(prefix, '@'); j >= 0 {
+			return prefix[:j], "", prefix[j+1:]
+		}
+		return "", "", prefix
+	}
+	name, rest := prefix[:i], prefix[i+1:]
+	if j := strings.IndexByte(rest, '@'); j >= 0 {
+		return name, rest[:j], rest[j+1:]
+	}
+	return name, rest, ""
+}
+
+func parseIRCTags(raw string) map[string]string {
+	tags := map[string]string{}
+	for _, tag := range strings.Split(raw, ";") {
+		if tag == "" {
+			continue
+		}
+		spl := strings.SplitN(tag, "=", 2)
+		value := ""
+		if len(spl) > 1 {
+			value = unescapeTagValue(spl[1])
+		}
+		tags[spl[0]] = value
+	}
+	return tags
+}
+
+func unescapeTagValue(v string) string {
+	if strings.IndexByte(v, '\\') < 0 {
+		return v
+	}
+	var b bytes.Buffer
+	for i := 0; i < len(v); i++ {
+		if v[i] != '\\' {
+			b.WriteByte(v[i])
+			continue
+		}
+		i++
+		if i >= len(v) {
+			break
+		}
+		switch v[i] {
+		case ':':
+			b.WriteByte(';')
+		case 's':
+			b.WriteByte(' ')
+		case 'r':
+			b.WriteByte('\r')
+		case 'n':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(v[i])
+		}
+	}
+	return b.String()
+}
+
+func escapeTagValue(v string) string {
+	var b bytes.Buffer
+	for i := 0; i < len(v); i++ {
+		switch v[i] {
+		case ';':
+			b.WriteString(`\:`)
+		case ' ':
+			b.WriteString(`\s`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\n':
+			b.WriteString(`\n`)
+		default:
+			b.WriteByte(v[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,198 @@
+package twitch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseIRC(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    *IRCMessage
+		wantErr bool
+	}{
+		{
+			name: "privmsg with escaped tags",
+			line: `@badge-info=;system-msg=5\sraiders\sfrom\:\sme\\you;empty= :nick!user@host.tmi.twitch.tv PRIVMSG #chan :hello :)`,
+			want: &IRCMessage{
+				Tags:        map[string]string{"badge-info": "", "system-msg": `5 raiders from; me\you`, "empty": ""},
+				Prefix:      "nick!user@host.tmi.twitch.tv",
+				Name:        "nick",
+				User:        "user",
+				Host:        "host.tmi.twitch.tv",
+				Command:     "PRIVMSG",
+				Params:      []string{"#chan"},
+				Trailing:    "hello :)",
+				HasTrailing: true,
+			},
+		},
+		{
+			name: "newline escapes",
+			line: `@msg=a\rb\nc\ :tmi.twitch.tv NOTICE * :x`,
+			want: &IRCMessage{
+				Tags:        map[string]string{"msg": "a\rb\nc"},
+				Prefix:      "tmi.twitch.tv",
+				Host:        "tmi.twitch.tv",
+				Command:     "NOTICE",
+				Params:      []string{"*"},
+				Trailing:    "x",
+				HasTrailing: true,
+			},
+		},
+		{
+			name: "numeric with params",
+			line: ":nick.tmi.twitch.tv 353 nick = #chan :a b c",
+			want: &IRCMessage{
+				Tags:        map[string]string{},
+				Prefix:      "nick.tmi.twitch.tv",
+				Host:        "nick.tmi.twitch.tv",
+				Command:     "353",
+				Params:      []string{"nick", "=", "#chan"},
+				Trailing:    "a b c",
+				HasTrailing: true,
+			},
+		},
+		{
+			name: "no prefix",
+			line: "PING :tmi.twitch.tv\r\n",
+			want: &IRCMessage{
+				Tags:        map[string]string{},
+				Command:     "PING",
+				Trailing:    "tmi.twitch.tv",
+				HasTrailing: true,
+			},
+		},
+		{
+			name: "reconnect",
+			line: ":tmi.twitch.tv RECONNECT",
+			want: &IRCMessage{
+				Tags:    map[string]string{},
+				Prefix:  "tmi.twitch.tv",
+				Host:    "tmi.twitch.tv",
+				Command: "RECONNECT",
+			},
+		},
+		{name: "empty", line: "", wantErr: true},
+		{name: "only tags", line: "@a=b", wantErr: true},
+		{name: "only prefix", line: ":tmi.twitch.tv", wantErr: true},
+		{name: "bad command", line: ":tmi.twitch.tv PRIV-MSG #a", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseIRC(tt.line)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. ParseIRC() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		tt.want.Raw = tt.line
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. ParseIRC() = \n%#v\n, want \n%#v\n", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseTyped(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want interface{}
+	}{
+		{
+			name: "notice",
+			line: `@msg-id=msg_slowmode :tmi.twitch.tv NOTICE #chan :This room is in slow mode.`,
+			want: &Notice{},
+		},
+		{
+			name: "clearmsg",
+			line: `@login=ronni;room-id=;target-msg-id=abc-123-def;tmi-sent-ts=1642720582342 :tmi.twitch.tv CLEARMSG #dallas :HeyGuys`,
+			want: &ClearMsg{},
+		},
+		{
+			name: "whisper",
+			line: `@badges=;color=;display-name=Petsgomoo;emotes=;message-id=306;thread-id=12345678_87654321;turbo=0;user-id=87654321;user-type= :petsgomoo!petsgomoo@petsgomoo.tmi.twitch.tv WHISPER foo :hello`,
+			want: &Whisper{},
+		},
+		{
+			name: "hosttarget",
+			line: `:tmi.twitch.tv HOSTTARGET #abc :xyz 10`,
+			want: &HostTarget{},
+		},
+		{
+			name: "reconnect",
+			line: `:tmi.twitch.tv RECONNECT`,
+			want: &Reconnect{},
+		},
+		{
+			name: "globaluserstate",
+			line: `@badge-info=;badges=;color=#0D4200;display-name=dallas;emote-sets=0,33,50;user-id=12345678;user-type=admin :tmi.twitch.tv GLOBALUSERSTATE`,
+			want: &GlobalUserState{},
+		},
+		{
+			name: "numeric",
+			line: `:tmi.twitch.tv 001 nick :Welcome, GLHF!`,
+			want: &Numeric{},
+		},
+		{
+			name: "part",
+			line: `:ronni!ronni@ronni.tmi.twitch.tv PART #dallas`,
+			want: &Message{},
+		},
+		{
+			name: "malformed emotes range",
+			line: `@emotes=25:0-99999,a-b/:;badges=x :a!a@a.tmi.twitch.tv PRIVMSG #x :Kappa`,
+			want: &Message{},
+		},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.line)
+		if err != nil {
+			t.Errorf("%q. Parse() error = %v", tt.name, err)
+			continue
+		}
+		if reflect.TypeOf(got) != reflect.TypeOf(tt.want) {
+			t.Errorf("%q. Parse() = %T, want %T", tt.name, got, tt.want)
+		}
+	}
+	n, _ := Parse(`@msg-id=msg_slowmode :tmi.twitch.tv NOTICE #chan :This room is in slow mode.`)
+	if notice := n.(*Notice); notice.MsgID != "msg_slowmode" || notice.Channel != "chan" {
+		t.Errorf("Parse() notice = %+v", notice)
+	}
+	h, _ := Parse(`:tmi.twitch.tv HOSTTARGET #abc :xyz 10`)
+	if host := h.(*HostTarget); host.Target != "xyz" || host.Viewers != 10 {
+		t.Errorf("Parse() hosttarget = %+v", host)
+	}
+	w, _ := Parse(`@message-id=306;thread-id=1_2 :petsgomoo!petsgomoo@petsgomoo.tmi.twitch.tv WHISPER foo :hello`)
+	if whisper := w.(*Whisper); whisper.To != "foo" || whisper.User != "petsgomoo" || whisper.Text != "hello" {
+		t.Errorf("Parse() whisper = %+v", whisper)
+	}
+}
+
+func TestParseReply(t *testing.T) {
+	line := `@badges=;display-name=Foo;id=b34ccfc7;reply-parent-display-name=Bar;reply-parent-msg-body=hello\sthere;reply-parent-msg-id=6b13e51b;reply-parent-user-id=123;reply-parent-user-login=bar;room-id=1;user-id=2 :foo!foo@foo.tmi.twitch.tv PRIVMSG #chan :@bar hi`
+	m, err := ParseMessage(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ReplyParent{MsgID: "6b13e51b", UserID: "123", UserLogin: "bar", DisplayName: "Bar", Body: "hello there"}
+	if !reflect.DeepEqual(m.Reply, want) {
+		t.Errorf("ParseMessage() Reply = %v, want %v", m.Reply, want)
+	}
+	if m.GetMessageID() != "b34ccfc7" {
+		t.Errorf("GetMessageID() = %v, want %v", m.GetMessageID(), "b34ccfc7")
+	}
+	if _, ok := m.Tags["reply-parent-msg-id"]; ok {
+		t.Error("reply tags should not stay in Tags")
+	}
+}
+
+func TestEscapeTagValue(t *testing.T) {
+	tests := []string{"", "plain", "a b;c\\d\r\n", `\s`}
+	for _, v := range tests {
+		if got := unescapeTagValue(escapeTagValue(v)); got != v {
+			t.Errorf("%q. unescapeTagValue(escapeTagValue()) = %q, want %q", v, got, v)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package twitch
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/FireGM/chats/interfaces"
+)
+
+// ChannelState is membership state of channel
+type ChannelState int
+
+const (
+	// ChannelJoining is JOIN sent and not confirmed yet
+	ChannelJoining ChannelState = iota
+	// ChannelJoined is JOIN confirmed by server echo
+	ChannelJoined
+	// ChannelParting is PART sent and not confirmed yet
+	ChannelParting
+	// ChannelFailed is JOIN refused by server, see ChannelInfo.Err
+	ChannelFailed
+)
+
+func (s ChannelState) String() string {
+	switch s {
+	case ChannelJoining:
+		return "joining"
+	case ChannelJoined:
+		return "joined"
+	case ChannelParting:
+		return "parting"
+	case ChannelFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// ChannelInfo is membership of bot in channel
+type ChannelInfo struct {
+	Name  string
+	State ChannelState
+	// JoinedAt is time of confirmation, zero until joined
+	JoinedAt time.Time
+	Err      error
+}
+
+// JoinError is NOTICE which refused JOIN
+type JoinError struct {
+	Channel string
+	MsgID   string
+	Text    string
+}
+
+func (e *JoinError) Error() string {
+	return "twitch: join #" + e.Channel + ": " + e.MsgID + ": " + e.Text
+}
+
+var errJoinTimeout = errors.New("join is not confirmed in time")
+
+// joinFailures is NOTICE msg-id which means JOIN is refused
+var joinFailures = map[string]bool{
+	"msg_channel_suspended": true,
+	"msg_channel_blocked":   true,
+	"tos_ban":               true,
+}
+
+type channel struct {
+	info ChannelInfo
+	done chan struct{}
+	// userState is true after USERSTATE sent by server on join
+	userState bool
+}
+
+func newChannel(name string) *channel {
+	return &channel{info: ChannelInfo{Name: name, State: ChannelJoining}, done: make(chan struct{})}
+}
+
+// finish wakes JoinAndWait
+func (c *channel) finish(state ChannelState, err error) {
+	c.info.State = state
+	c.info.Err = err
+	if state == ChannelJoined {
+		c.info.JoinedAt = time.Now()
+		c.userState = false
+	}
+	select {
+	case <-c.done:
+	default:
+		close(c.done)
+	}
+}
+
+func channelName(ch string) string {
+	return strings.ToLower(strings.TrimPrefix(ch, "#"))
+}
+
+// JoinAndWait joins channel and waits for confirmation or refusal by server
+func (b *Bot) JoinAndWait(ch string, timeout time.Duration) error {
+	ch = channelName(ch)
+	if err := b.Join(ch); err != nil {
+		return err
+	}
+	b.locker.RLock()
+	c, ok := b.channels[ch]
+	var done chan struct{}
+	if ok {
+		done = c.done
+	}
+	b.locker.RUnlock()
+	if !ok {
+		return errNotConnected
+	}
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		return errJoinTimeout
+	}
+	b.locker.RLock()
+	defer b.locker.RUnlock()
+	return c.info.Err
+}
+
+// Channels returns membership of every channel which bot joins or joined
+func (b *Bot) Channels() map[string]ChannelInfo {
+	b.locker.RLock()
+	defer b.locker.RUnlock()
+	channels := make(map[string]ChannelInfo, len(b.channels))
+	for name, c := range b.channels {
+		channels[name] = c.info
+	}
+	return channels
+}
+
+// updateMembership applies JOIN and PART echoes of bot and NOTICE with refused JOIN
+func (b *Bot) updateMembership(m interfaces.Message) {
+	switch m := m.(type) {
+	case *Message:
+		if m.Type != joinMsg && m.Type != partMsg || !strings.EqualFold(m.User, b.name) {
+			return
+		}
+		b.locker.Lock()
+		defer b.locker.Unlock()
+		c, ok := b.channels[m.Channel]
+		if !ok {
+			return
+		}
+		if m.Type == joinMsg && c.info.State == ChannelJoining {
+			c.finish(ChannelJoined, nil)
+		} else if m.Type == partMsg && c.info.State == ChannelParting {
+			delete(b.channels, m.Channel)
+		}
+	case *Notice:
+		if !joinFailures[m.MsgID] {
+			return
+		}
+		b.locker.Lock()
+		defer b.locker.Unlock()
+		if c, ok := b.channels[m.Channel]; ok && c.info.State == ChannelJoining {
+			c.finish(ChannelFailed, &JoinError{Channel: m.Channel, MsgID: m.MsgID, Text: m.Text})
+		}
+	}
+}
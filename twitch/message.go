@@ -3,52 +3,102 @@
 package twitch
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"html"
 	"html/template"
-	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 const (
-	privMsg       = "PRIVMSG"
-	clearMsg      = "CLEARCHAT"
-	usernoticeMsg = "USERNOTICE"
-	userStateMsg  = "USERSTATE"
-	joinMsg       = "JOIN"
-	roomStateMsg  = "ROOMSTATE"
+	privMsg            = "PRIVMSG"
+	clearMsg           = "CLEARCHAT"
+	usernoticeMsg      = "USERNOTICE"
+	userStateMsg       = "USERSTATE"
+	joinMsg            = "JOIN"
+	roomStateMsg       = "ROOMSTATE"
+	partMsg            = "PART"
+	noticeMsg          = "NOTICE"
+	clearSingleMsg     = "CLEARMSG"
+	whisperMsg         = "WHISPER"
+	hostTargetMsg      = "HOSTTARGET"
+	reconnectMsg       = "RECONNECT"
+	globalUserStateMsg = "GLOBALUSERSTATE"
+	pingMsg            = "PING"
+	pongMsg            = "PONG"
+	capMsg             = "CAP"
 )
 
-var messageRegexp = regexp.MustCompile(`^(?P<tags>.*):((?P<username>.*)!(.*)@(.*))?(\.*)tmi.twitch.tv (?P<typeOfMessage>PRIVMSG|USERNOTICE|CLEARCHAT|USERSTATE|JOIN|ROOMSTATE) #(?P<channel>\w*)( (:?)(?P<message>.*))?`)
-var subNamesRegexp = messageRegexp.SubexpNames()
+var supportedCommands = map[string]bool{
+	privMsg: true, clearMsg: true, usernoticeMsg: true, userStateMsg: true, joinMsg: true,
+	roomStateMsg: true, partMsg: true, noticeMsg: true, clearSingleMsg: true, whisperMsg: true,
+	hostTargetMsg: true, reconnectMsg: true, globalUserStateMsg: true, pingMsg: true,
+	pongMsg: true, capMsg: true,
+}
+
+const emoteURLFormat = "https://static-cdn.jtvnw.net/emoticons/v2/%s/%s/%s/%s"
 
-const emotUrl = "https://static-cdn.jtvnw.net/emoticons/v1/%s/1.0"
+// EmoteFormat of emote images: "default" (animated if emote has animation), "static" or "animated"
+var EmoteFormat = "default"
 
-// type Emote struct {
-// 	ID     int
-// 	Name   string
-// 	Ranges [2]int
-// }
+// EmoteTheme of emote images: "dark" or "light"
+var EmoteTheme = "dark"
 
-// type emotes []Emote
+var emoteScales = []string{"1.0", "2.0", "3.0"}
 
-// func (e emotes) Len() int {
-// 	return len(e)
-// }
-// func (e emotes) Swap(i, j int) {
-// 	e[i], e[j] = e[j], e[i]
-// }
-// func (e emotes) Less(i, j int) bool {
-// 	return e[i].Ranges[0] < e[j].Ranges[0]
-// }
+// modifiers of emotes, added to id like emotesv2_..._HF
+var emoteModifiers = map[string]bool{"BW": true, "HF": true, "SG": true, "SQ": true, "TK": true}
+
+// EmotePosition is range of runes in text, end included
+type EmotePosition struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
 
 type Emote struct {
-	Name  string `json:"name"`
-	ID    string `json:"id"`
-	Type  string `json:"type"`
-	Count int    `json:"count"`
+	Name      string          `json:"name"`
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Count     int             `json:"count"`
+	Modifier  string          `json:"modifier,omitempty"`
+	Positions []EmotePosition `json:"positions,omitempty"`
+}
+
+// URL of emote image with scale "1.0", "2.0" or "3.0"
+func (e *Emote) URL(scale string) string {
+	return fmt.Sprintf(emoteURLFormat, e.ID, EmoteFormat, EmoteTheme, scale)
+}
+
+// SrcSet of emote image for all scales
+func (e *Emote) SrcSet() string {
+	set := make([]string, len(emoteScales))
+	for i, scale := range emoteScales {
+		set[i] = e.URL(scale) + " " + scale[:1] + "x"
+	}
+	return strings.Join(set, ", ")
+}
+
+func (e *Emote) render() string {
+	class := "smile"
+	if e.Modifier != "" {
+		class += " smile-modifier-" + strings.ToLower(e.Modifier)
+	}
+	return `<img class="` + class + `" src="` + e.URL(emoteScales[0]) + `" srcset="` + e.SrcSet() +
+		`" alt="` + html.EscapeString(e.Name) + `">`
+}
+
+// ReplyParent is message which is replied to, from reply-parent-* tags
+type ReplyParent struct {
+	MsgID             string `json:"msg_id"`
+	UserID            string `json:"user_id"`
+	UserLogin         string `json:"user_login"`
+	DisplayName       string `json:"display_name"`
+	Body              string `json:"body"`
+	ThreadMsgID       string `json:"thread_msg_id,omitempty"`
+	ThreadParentLogin string `json:"thread_parent_login,omitempty"`
 }
 
 type Message struct {
@@ -58,6 +108,8 @@ type Message struct {
 	DisplayName string            `json:"display_name"`
 	Emotes      map[string]*Emote `json:"emotes"`
 	Mod         int               `json:"mod"`
+	Bits        int               `json:"bits,omitempty"`
+	Reply       *ReplyParent      `json:"reply,omitempty"`
 	RoomID      int               `json:"room_id"`
 	Channel     string            `json:"channel"`
 	Text        string            `json:"text"`
@@ -70,6 +122,11 @@ type Message struct {
 	FullRender     template.HTML `json:"full_render"`
 }
 
+// command is IRC command of line, typed messages embed Message and share it
+func (m *Message) command() string {
+	return m.Type
+}
+
 func (m *Message) IsFromUser() bool {
 	if m.Type == privMsg {
 		return true
@@ -77,6 +134,11 @@ func (m *Message) IsFromUser() bool {
 	return false
 }
 
+// IsPrivate reports message sent only to bot, see Whisper
+func (m *Message) IsPrivate() bool {
+	return false
+}
+
 func (m Message) GetChatName() string {
 	return "twitch"
 }
@@ -89,23 +151,61 @@ func (m *Message) GetUID() string {
 	return m.User
 }
 
+// GetMessageID is id of message, used by CLEARMSG and replies
+func (m *Message) GetMessageID() string {
+	return m.Tags["id"]
+}
+
+type emoteAt struct {
+	EmotePosition
+	emote *Emote
+}
+
 func (m *Message) GetRenderSmiles() template.HTML {
-	escaped := html.EscapeString(m.Text)
 	if len(m.Emotes) < 1 {
-		return template.HTML(template.HTML(escaped))
+		return template.HTML(m.renderText(m.Text))
 	}
+	runes := []rune(m.Text)
+	var positions []emoteAt
 	for _, emote := range m.Emotes {
-		var url string
-		switch emote.Type {
-		case "twitch":
-			url = fmt.Sprintf(emotUrl, emote.ID)
+		ps := emote.Positions
+		if len(ps) == 0 {
+			ps = wordPositions(runes, emote.Name)
+		}
+		for _, p := range ps {
+			positions = append(positions, emoteAt{p, emote})
+		}
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].Start < positions[j].Start
+	})
+	var buf bytes.Buffer
+	last := 0
+	for _, p := range positions {
+		if p.Start < last || p.End >= len(runes) || p.Start > p.End {
+			continue
+		}
+		buf.WriteString(m.renderText(string(runes[last:p.Start])))
+		buf.WriteString(p.emote.render())
+		last = p.End + 1
+	}
+	buf.WriteString(m.renderText(string(runes[last:])))
+	return template.HTML(buf.String())
+}
 
+// wordPositions finds name as separate word, used when emote has no positions
+func wordPositions(runes []rune, name string) []EmotePosition {
+	var ps []EmotePosition
+	n := []rune(name)
+	for i := 0; i+len(n) <= len(runes); i++ {
+		if (i > 0 && runes[i-1] != ' ') || (i+len(n) < len(runes) && runes[i+len(n)] != ' ') {
+			continue
+		}
+		if string(runes[i:i+len(n)]) == name {
+			ps = append(ps, EmotePosition{Start: i, End: i + len(n) - 1})
 		}
-		escaped = strings.Replace(escaped, emote.Name,
-			`<img class="smile" src="`+url+`" alt="`+emote.Name+`">`,
-			-1)
 	}
-	return template.HTML(escaped)
+	return ps
 }
 
 func (m *Message) GetRenderMessHTML() template.HTML {
@@ -125,10 +225,9 @@ func (m *Message) GetRenderNicknameHTML() template.HTML {
 		url := ""
 		alt := ""
 		if k == "subscriber" {
-			url, alt = getBadgeSubscriber(m.Channel, v)
+			url, alt = getBadgeSubscriber(m.RoomID, v)
 		} else {
-			url = badges[k][v].ImageURL1x
-			alt = badges[k][v].Title
+			url, alt = getBadge(k, v)
 		}
 		if url != "" {
 			badge += fmt.Sprintf(`<img class="badge twitch-badge" src="%s" alt="%s">`, url, alt)
@@ -170,76 +269,62 @@ func (m *Message) IsClearMessage() bool {
 	return m.Type == clearMsg
 }
 
+// IsModerator checks moderator badge and mod tag, broadcaster is not moderator, see IsBroadcaster
 func (m *Message) IsModerator() (bool, string) {
 	v, ok := m.Badges["moderator"]
-	return ok, badges["moderator"][v].ImageURL1x
+	url, _ := getBadge("moderator", v)
+	return ok || m.Mod == 1, url
 }
 
+// IsSubscriber checks subscriber and founder badges, url is taken only from cache of badges
 func (m *Message) IsSubscriber() (bool, string) {
 	if v, ok := m.Badges["subscriber"]; ok {
-		url, _ := getBadgeSubscriber(m.Channel, v)
+		url, _ := cachedBadgeSubscriber(m.RoomID, v)
+		return true, url
+	}
+	if v, ok := m.Badges["founder"]; ok {
+		url, _ := getBadge("founder", v)
 		return true, url
 	}
-	return false, ""
+	return m.Tags["subscriber"] == "1", ""
 }
 
 func ParseMessage(line string) (Message, error) {
+	m, _, err := parseLine(line)
+	return m, err
+}
+
+func parseLine(line string) (Message, *IRCMessage, error) {
 	var m Message
-	m, err := messageTypeParse(line)
+	irc, err := ParseIRC(line)
 	if err != nil {
-		return m, err
+		return m, nil, err
+	}
+	if !supportedCommands[irc.Command] && !isDigits(irc.Command) {
+		return m, irc, errors.New("unsupported line of chat")
 	}
+	m = messageFromIRC(irc)
 	if m.Type == clearMsg {
 		m.User = m.Text
 	}
 	m.RawMessage = line
-	return m, nil
+	return m, irc, nil
 }
 
-func messageTypeParse(line string) (Message, error) {
+func messageFromIRC(irc *IRCMessage) Message {
 	var message Message
-	data, err := getDataFromLine(line)
-	if err != nil {
-		return message, err
-	}
-	message.Type = data["typeOfMessage"]
-	message.Channel = data["channel"]
-	message.Text = data["message"]
-	message.User = data["username"]
-	err = parseTags(data["tags"], &message)
-	if err != nil {
-		return message, err
-	}
-	return message, nil
+	message.Type = irc.Command
+	message.Channel = irc.Channel()
+	message.Text = irc.Trailing
+	message.User = irc.Name
+	parseTags(irc.Tags, &message)
+	return message
 }
 
-func getDataFromLine(line string) (map[string]string, error) {
-	md := map[string]string{}
-	r := messageRegexp.FindAllStringSubmatch(line, 1)
-	if len(r) < 1 {
-		return nil, errors.New("unsupported line of chat")
-	}
-	for i, n := range r[0] {
-		md[subNamesRegexp[i]] = n
-	}
-	if v, ok := md["typeOfMessage"]; !ok || v == "" {
-		return nil, errors.New("Unsupported message type")
-	}
-	return md, nil
-}
-
-func parseTags(tagsString string, m *Message) error {
-	tagsString = strings.TrimPrefix(tagsString, "@")
-	tagsString = strings.TrimSuffix(tagsString, " ")
-	tags := strings.Split(tagsString, ";")
+func parseTags(tags map[string]string, m *Message) {
 	m.Tags = make(map[string]string)
-	for _, tag := range tags {
-		spl := strings.SplitN(tag, "=", 2)
-		value := ""
-		if len(spl) > 1 {
-			value = spl[1]
-		}
-		switch spl[0] {
+	for key, value := range tags {
+		switch key {
 		case "":
 			continue
 		case "badges":
@@ -252,15 +337,44 @@ func parseTags(tagsString string, m *Message) error {
 			m.Emotes = getEmotions(value, m.Text)
 		case "mod":
 			m.Mod, _ = strconv.Atoi(value)
+		case "bits":
+			m.Bits, _ = strconv.Atoi(value)
+		case "reply-parent-msg-id", "reply-parent-user-id", "reply-parent-user-login",
+			"reply-parent-display-name", "reply-parent-msg-body",
+			"reply-thread-parent-msg-id", "reply-thread-parent-user-login":
+			setReply(m, key, value)
 		case "room-id":
 			m.RoomID, _ = strconv.Atoi(value)
 		default:
-			m.Tags[spl[0]] = value
+			m.Tags[key] = value
 		}
 	}
-	return nil
 }
 
+// setReply fills parent of reply from reply-parent-* and reply-thread-parent-* tags
+func setReply(m *Message, key, value string) {
+	if m.Reply == nil {
+		m.Reply = &ReplyParent{}
+	}
+	switch key {
+	case "reply-parent-msg-id":
+		m.Reply.MsgID = value
+	case "reply-parent-user-id":
+		m.Reply.UserID = value
+	case "reply-parent-user-login":
+		m.Reply.UserLogin = value
+	case "reply-parent-display-name":
+		m.Reply.DisplayName = value
+	case "reply-parent-msg-body":
+		m.Reply.Body = value
+	case "reply-thread-parent-msg-id":
+		m.Reply.ThreadMsgID = value
+	case "reply-thread-parent-user-login":
+		m.Reply.ThreadParentLogin = value
+	}
+}
+
+// getEmotions parses emotes tag: id:start-end,start-end/id:start-end
 func getEmotions(emotions string, text string) map[string]*Emote {
 	emotes := map[string]*Emote{}
 
@@ -270,26 +384,48 @@ func getEmotions(emotions string, text string) map[string]*Emote {
 
 	runes := []rune(text)
 
-	emoteSlice := strings.Split(emotions, "/")
-	for i := range emoteSlice {
-		spl := strings.Split(emoteSlice[i], ":")
-		pos := strings.Split(spl[1], ",")
-		sp := strings.Split(pos[0], "-")
-		start, _ := strconv.Atoi(sp[0])
-		end, _ := strconv.Atoi(sp[1])
+	for _, emoteStr := range strings.Split(emotions, "/") {
+		spl := strings.SplitN(emoteStr, ":", 2)
+		if len(spl) != 2 || spl[0] == "" {
+			continue
+		}
 		id := spl[0]
-		e := &Emote{
-			Type:  "twitch",
-			ID:    id,
-			Count: strings.Count(emoteSlice[i], "-"),
-			Name:  string(runes[start : end+1]),
+		var positions []EmotePosition
+		for _, pos := range strings.Split(spl[1], ",") {
+			sp := strings.Split(pos, "-")
+			if len(sp) != 2 {
+				continue
+			}
+			start, err1 := strconv.Atoi(sp[0])
+			end, err2 := strconv.Atoi(sp[1])
+			if err1 != nil || err2 != nil || start < 0 || start > end || end >= len(runes) {
+				continue
+			}
+			positions = append(positions, EmotePosition{Start: start, End: end})
 		}
-
-		emotes[e.Name] = e
+		if len(positions) == 0 {
+			continue
+		}
+		name := string(runes[positions[0].Start : positions[0].End+1])
+		e, ok := emotes[name]
+		if !ok {
+			e = &Emote{Type: "twitch", ID: id, Name: name, Modifier: emoteModifier(id)}
+			emotes[name] = e
+		}
+		e.Positions = append(e.Positions, positions...)
+		e.Count = len(e.Positions)
 	}
 	return emotes
 }
 
+func emoteModifier(id string) string {
+	i := strings.LastIndex(id, "_")
+	if i < 0 || !emoteModifiers[id[i+1:]] {
+		return ""
+	}
+	return id[i+1:]
+}
+
 func getBadges(badges string) map[string]string {
 	var badgeMap = make(map[string]string)
 	for _, badge := range strings.Split(badges, ",") {
@@ -302,28 +438,3 @@ func getBadges(badges string) map[string]string {
 	}
 	return badgeMap
 }
-
-func getColor(line string, m *Message) (string, error) {
-	colorName, color, next := getNextAndSplited(line)
-	if colorName != "color" {
-		return "", errors.New("no color")
-	}
-	m.Color = color
-	return next, nil
-}
-
-func getDisplayName(line string, m *Message) (string, error) {
-	name, displayName, next := getNextAndSplited(line)
-	if name != "display-name" {
-		return "", errors.New("No display name")
-	}
-	m.DisplayName = displayName
-	return next, nil
-}
-
-func getNextAndSplited(line string) (string, string, string) {
-	splitted := strings.SplitN(line[1:], ";", 1)
-	next := splitted[1]
-	splited := strings.Split(splitted[0], "=")
-	return splited[0], splited[1], next
-}
@@ -40,13 +40,14 @@ func TestParseMessage(t *testing.T) {
 				Color:       "#E1630E",
 				DisplayName: "Haunterxx",
 				Tags:        map[string]string{"sent-ts": "1496852224609", "id": "04de4e6b-646d-4e02-94a9-d0ee80c93a3f", "subscriber": "1", "tmi-sent-ts": "1496852221750", "turbo": "0", "user-id": "39647543", "user-type": ""},
-				Emotes:      map[string]*Emote{"PogChamp": &Emote{Type: "twitch", ID: "88", Count: 1, Name: "PogChamp"}},
-				Mod:         0,
-				RoomID:      24991333,
-				Channel:     "imaqtpie",
-				Text:        "hashinshin PogChamp",
-				User:        "haunterxx",
-				RawMessage:  `@badges=subscriber/6;color=#E1630E;display-name=Haunterxx;emotes=88:11-18;id=04de4e6b-646d-4e02-94a9-d0ee80c93a3f;mod=0;room-id=24991333;sent-ts=1496852224609;subscriber=1;tmi-sent-ts=1496852221750;turbo=0;user-id=39647543;user-type= :haunterxx!haunterxx@haunterxx.tmi.twitch.tv PRIVMSG #imaqtpie :hashinshin PogChamp`,
+				Emotes: map[string]*Emote{"PogChamp": &Emote{Type: "twitch", ID: "88", Count: 1, Name: "PogChamp",
+					Positions: []EmotePosition{{Start: 11, End: 18}}}},
+				Mod:        0,
+				RoomID:     24991333,
+				Channel:    "imaqtpie",
+				Text:       "hashinshin PogChamp",
+				User:       "haunterxx",
+				RawMessage: `@badges=subscriber/6;color=#E1630E;display-name=Haunterxx;emotes=88:11-18;id=04de4e6b-646d-4e02-94a9-d0ee80c93a3f;mod=0;room-id=24991333;sent-ts=1496852224609;subscriber=1;tmi-sent-ts=1496852221750;turbo=0;user-id=39647543;user-type= :haunterxx!haunterxx@haunterxx.tmi.twitch.tv PRIVMSG #imaqtpie :hashinshin PogChamp`,
 			},
 		},
 		{
@@ -132,7 +133,7 @@ func TestMessagePublicFuns(t *testing.T) {
 		t.Errorf("Message.GetUID() = %v, want %v", message.GetUID(), "haunterxx")
 	}
 
-	smileRender := template.HTML(`hashinshin <img class="smile" src="https://static-cdn.jtvnw.net/emoticons/v1/88/1.0" alt="PogChamp">`)
+	smileRender := template.HTML(`hashinshin <img class="smile" src="https://static-cdn.jtvnw.net/emoticons/v2/88/default/dark/1.0" srcset="https://static-cdn.jtvnw.net/emoticons/v2/88/default/dark/1.0 1x, https://static-cdn.jtvnw.net/emoticons/v2/88/default/dark/2.0 2x, https://static-cdn.jtvnw.net/emoticons/v2/88/default/dark/3.0 3x" alt="PogChamp">`)
 	if message.GetRenderSmiles() != smileRender {
 		t.Errorf("Message.GetRenderSmiles() = %v, want %v", message.GetRenderSmiles(), smileRender)
 	}
@@ -204,3 +205,77 @@ func TestMessage_ToUser(t *testing.T) {
 		}
 	}
 }
+
+func TestMessage_GetRenderSmiles(t *testing.T) {
+	img := func(id, name, class string) string {
+		u := "https://static-cdn.jtvnw.net/emoticons/v2/" + id + "/default/dark/"
+		return `<img class="` + class + `" src="` + u + `1.0" srcset="` + u + `1.0 1x, ` + u + `2.0 2x, ` + u + `3.0 3x" alt="` + name + `">`
+	}
+	tests := []struct {
+		name string
+		line string
+		want template.HTML
+	}{
+		{
+			name: "every occurrence from positions",
+			line: `@emotes=25:0-4,18-22 :a!a@a.tmi.twitch.tv PRIVMSG #x :Kappa <b>NotKappa Kappa`,
+			want: template.HTML(img("25", "Kappa", "smile") + ` &lt;b&gt;NotKappa ` + img("25", "Kappa", "smile")),
+		},
+		{
+			name: "unicode before emote",
+			line: `@emotes=25:4-8/1902:10-14 :a!a@a.tmi.twitch.tv PRIVMSG #x :привKappa Keepo!`,
+			want: template.HTML(`прив` + img("25", "Kappa", "smile") + ` ` + img("1902", "Keepo", "smile") + `!`),
+		},
+		{
+			name: "modifier",
+			line: `@emotes=emotesv2_abc_HF:0-9 :a!a@a.tmi.twitch.tv PRIVMSG #x :KappaHF_HF`,
+			want: template.HTML(img("emotesv2_abc_HF", "KappaHF_HF", "smile smile-modifier-hf")),
+		},
+		{
+			name: "broken range ignored",
+			line: `@emotes=25:0-99,a-b :a!a@a.tmi.twitch.tv PRIVMSG #x :Kappa`,
+			want: template.HTML(`Kappa`),
+		},
+	}
+	for _, tt := range tests {
+		m, err := ParseMessage(tt.line)
+		if err != nil {
+			t.Errorf("%q. ParseMessage() error = %v", tt.name, err)
+			continue
+		}
+		if got := m.GetRenderSmiles(); got != tt.want {
+			t.Errorf("%q. Message.GetRenderSmiles() = \n%v\n, want \n%v\n", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMessage_Cheers(t *testing.T) {
+	SetCheermotes([]Cheermote{{Prefix: "Cheer", Tiers: []CheermoteTier{
+		{MinBits: 1, Color: "#979797", Images: map[string]map[string]map[string]string{
+			"dark": {"animated": {"1": "https://cdn/cheer/1.gif"}}}},
+		{MinBits: 100, Color: "#9c3ee8", Images: map[string]map[string]map[string]string{
+			"dark": {"animated": {"1": "https://cdn/cheer/100.gif"}}}},
+	}}})
+	defer SetCheermotes(nil)
+	m, err := ParseMessage(`@bits=150;emotes=25:20-24 :a!a@a.tmi.twitch.tv PRIVMSG #x :Cheer100 <3 cheer50 Kappa abc10`)
+	if err != nil {
+		t.Fatalf("ParseMessage() error = %v", err)
+	}
+	if m.Bits != 150 {
+		t.Errorf("Message.Bits = %d, want 150", m.Bits)
+	}
+	if _, ok := m.Tags["bits"]; ok {
+		t.Error("bits left in Message.Tags")
+	}
+	cheers := m.Cheers()
+	if len(cheers) != 2 || cheers[0].Amount != 100 || cheers[0].Tier.MinBits != 100 ||
+		cheers[1].Prefix != "cheer" || cheers[1].Amount != 50 || cheers[1].Tier.MinBits != 1 {
+		t.Errorf("Message.Cheers() = %+v", cheers)
+	}
+	want := template.HTML(`<span class="cheer twitch-cheer"><img class="cheermote twitch-cheermote" src="https://cdn/cheer/100.gif" alt="Cheer"><span class="cheer-amount" style="color: #9c3ee8">100</span></span>` +
+		` &lt;3 <span class="cheer twitch-cheer"><img class="cheermote twitch-cheermote" src="https://cdn/cheer/1.gif" alt="cheer"><span class="cheer-amount" style="color: #979797">50</span></span> ` +
+		`<img class="smile" src="https://static-cdn.jtvnw.net/emoticons/v2/25/default/dark/1.0" srcset="https://static-cdn.jtvnw.net/emoticons/v2/25/default/dark/1.0 1x, https://static-cdn.jtvnw.net/emoticons/v2/25/default/dark/2.0 2x, https://static-cdn.jtvnw.net/emoticons/v2/25/default/dark/3.0 3x" alt="Kappa"> abc10`)
+	if got := m.GetRenderSmiles(); got != want {
+		t.Errorf("Message.GetRenderSmiles() = \n%v\n, want \n%v\n", got, want)
+	}
+}
@@ -0,0 +1,152 @@
+package twitch
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var errUnknownUser = errors.New("user is not found")
+
+var errTimeoutDuration = errors.New("duration of timeout must be positive")
+
+var errNoMessageID = errors.New("id of message is empty, use ClearChat to delete all messages")
+
+// ErrNoHelix is returned by methods which work only with helix, see SetHelix
+var ErrNoHelix = errors.New("helix is not set")
+
+// SetHelix makes Ban, Timeout, Unban, DeleteMessage, ClearChat and chat mode setters use helix
+// instead of deprecated chat commands. Token must belong to bot account.
+// Client is used for badges too if SetHelix of package was not called
+func (b *Bot) SetHelix(h *Helix) {
+	b.helix = h
+	if getHelix() == nil {
+		SetHelix(h)
+	}
+}
+
+func (b *Bot) setUserID(id string) {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	b.userID = id
+}
+
+// moderatorID is id of bot account from GLOBALUSERSTATE or helix
+func (b *Bot) moderatorID() (string, error) {
+	b.locker.RLock()
+	id := b.userID
+	b.locker.RUnlock()
+	if id != "" {
+		return id, nil
+	}
+	id, err := b.helixUserID(b.name)
+	if err != nil {
+		return "", err
+	}
+	b.setUserID(id)
+	return id, nil
+}
+
+// broadcasterID is room id of joined channel or id from helix
+func (b *Bot) broadcasterID(ch string) (string, error) {
+	if rs, ok := b.RoomState(channelName(ch)); ok && rs.RoomID != 0 {
+		return strconv.Itoa(rs.RoomID), nil
+	}
+	return b.helixUserID(channelName(ch))
+}
+
+func (b *Bot) helixUserID(login string) (string, error) {
+	users, err := b.helix.Users(login)
+	if err != nil {
+		return "", err
+	}
+	if len(users) == 0 {
+		return "", errUnknownUser
+	}
+	return users[0].ID, nil
+}
+
+// helixIDs returns broadcaster and moderator ids for channel
+func (b *Bot) helixIDs(ch string) (string, string, error) {
+	broadcaster, err := b.broadcasterID(ch)
+	if err != nil {
+		return "", "", err
+	}
+	moderator, err := b.moderatorID()
+	if err != nil {
+		return "", "", err
+	}
+	return broadcaster, moderator, nil
+}
+
+func (b *Bot) helixBan(ch, nickname string, duration int) error {
+	broadcaster, moderator, err := b.helixIDs(ch)
+	if err != nil {
+		return err
+	}
+	user, err := b.helixUserID(nickname)
+	if err != nil {
+		return err
+	}
+	return b.helix.Ban(broadcaster, moderator, user, duration, "")
+}
+
+// Unban removes ban or timeout of user
+func (b *Bot) Unban(ch, nickname string) error {
+	if b.anonymous {
+		return ErrAnonymous
+	}
+	if b.helix == nil {
+		return b.SendMessageToChan(ch, fmt.Sprintf(".unban %s", nickname))
+	}
+	broadcaster, moderator, err := b.helixIDs(ch)
+	if err != nil {
+		return err
+	}
+	user, err := b.helixUserID(nickname)
+	if err != nil {
+		return err
+	}
+	return b.helix.Unban(broadcaster, moderator, user)
+}
+
+// DeleteMessage deletes message by id, see Message.GetMessageID
+func (b *Bot) DeleteMessage(ch, messageID string) error {
+	if b.anonymous {
+		return ErrAnonymous
+	}
+	if messageID == "" {
+		return errNoMessageID
+	}
+	if b.helix == nil {
+		return b.SendMessageToChan(ch, fmt.Sprintf(".delete %s", messageID))
+	}
+	broadcaster, moderator, err := b.helixIDs(ch)
+	if err != nil {
+		return err
+	}
+	return b.helix.DeleteMessage(broadcaster, moderator, messageID)
+}
+
+// ClearChat deletes all messages of channel
+func (b *Bot) ClearChat(ch string) error {
+	if b.anonymous {
+		return ErrAnonymous
+	}
+	if b.helix == nil {
+		return b.SendMessageToChan(ch, ".clear")
+	}
+	broadcaster, moderator, err := b.helixIDs(ch)
+	if err != nil {
+		return err
+	}
+	return b.helix.ClearChat(broadcaster, moderator)
+}
+
+func (b *Bot) updateChatSettings(ch string, settings ChatSettings) error {
+	broadcaster, moderator, err := b.helixIDs(ch)
+	if err != nil {
+		return err
+	}
+	return b.helix.UpdateChatSettings(broadcaster, moderator, settings)
+}
@@ -0,0 +1,344 @@
+package twitch
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/FireGM/chats/dispatch"
+	"github.com/FireGM/chats/interfaces"
+)
+
+// PoolOptions of Pool, zero values are replaced with defaults
+type PoolOptions struct {
+	// Name and OAuth of account, empty OAuth is anonymous pool
+	Name  string
+	OAuth string
+	// ChannelsPerConn is max count of channels on one connection
+	ChannelsPerConn int
+	// JoinRate JOINs are sent per JoinWindow, twitch allows 20 per 10 seconds
+	JoinRate   int
+	JoinWindow time.Duration
+	// Server is irc server address, see Bot.SetServer
+	Server string
+	// Transport of connections, it overrides Server
+	Transport Transport
+}
+
+// NewPool creates pool of connections which looks like one bot.
+// Channels are spread across connections, JOINs are paced within limits
+// and channels of dropped connection are joined again on others
+func NewPool(opts PoolOptions, handle func(interfaces.Message, interfaces.Bot)) *Pool {
+	if opts.ChannelsPerConn == 0 {
+		opts.ChannelsPerConn = 50
+	}
+	if opts.JoinRate == 0 {
+		opts.JoinRate = 20
+	}
+	if opts.JoinWindow == 0 {
+		opts.JoinWindow = 10 * time.Second
+	}
+	return &Pool{opts: opts, handleFunc: handle,
+		channels: map[string]*Bot{}, pending: map[string]bool{},
+		wake: make(chan struct{}, 1), stop: make(chan struct{})}
+}
+
+var errNoConnection = errors.New("no connection in pool")
+
+type Pool struct {
+	opts       PoolOptions
+	handleFunc func(interfaces.Message, interfaces.Bot)
+	dispatcher dispatch.Holder
+
+	members  []*Bot
+	channels map[string]*Bot
+	pending  map[string]bool
+	queue    []string
+	locker   sync.Mutex
+
+	joins []time.Time
+	wake  chan struct{}
+	stop  chan struct{}
+	once  sync.Once
+}
+
+// SetDispatcher replaces dispatcher of handler calls, call it before Connect
+func (p *Pool) SetDispatcher(d *dispatch.Dispatcher) {
+	p.dispatcher.Set(d)
+}
+
+// Connect opens first connection and starts joining of channels
+func (p *Pool) Connect() error {
+	p.dispatcher.Open()
+	b, err := p.newMember()
+	if err != nil {
+		return err
+	}
+	p.locker.Lock()
+	p.members = append(p.members, b)
+	p.locker.Unlock()
+	go p.joinLoop()
+	return nil
+}
+
+func (p *Pool) Disconnect() error {
+	p.once.Do(func() { close(p.stop) })
+	p.locker.Lock()
+	members := p.members
+	p.members = nil
+	p.locker.Unlock()
+	var err error
+	for _, b := range members {
+		if e := b.Disconnect(); e != nil {
+			err = e
+		}
+	}
+	p.dispatcher.Close()
+	return err
+}
+
+// Join queues channel, it is joined when pacing allows
+func (p *Pool) Join(ch string) error {
+	ch = channelName(ch)
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	if _, ok := p.channels[ch]; ok || p.pending[ch] {
+		return nil
+	}
+	p.enqueueLocked(ch)
+	return nil
+}
+
+func (p *Pool) Leave(ch string) error {
+	ch = channelName(ch)
+	p.locker.Lock()
+	b, ok := p.channels[ch]
+	delete(p.channels, ch)
+	delete(p.pending, ch)
+	p.locker.Unlock()
+	if !ok {
+		return nil
+	}
+	return b.Leave(ch)
+}
+
+func (p *Pool) SendMessageToChan(ch, message string) error {
+	b := p.botFor(ch)
+	if b == nil {
+		return errNoConnection
+	}
+	return b.SendMessageToChan(ch, message)
+}
+
+func (p *Pool) Ban(ch, nickname string) error {
+	b := p.botFor(ch)
+	if b == nil {
+		return errNoConnection
+	}
+	return b.Ban(ch, nickname)
+}
+
+func (p *Pool) Timeout(ch, nickname string, t int) error {
+	b := p.botFor(ch)
+	if b == nil {
+		return errNoConnection
+	}
+	return b.Timeout(ch, nickname, t)
+}
+
+// Connections is count of open connections
+func (p *Pool) Connections() int {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	return len(p.members)
+}
+
+// Pending is count of channels waiting for join
+func (p *Pool) Pending() int {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	return len(p.pending)
+}
+
+// botFor returns connection of channel or any connection
+func (p *Pool) botFor(ch string) *Bot {
+	ch = channelName(ch)
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	if b, ok := p.channels[ch]; ok {
+		return b
+	}
+	if len(p.members) > 0 {
+		return p.members[0]
+	}
+	return nil
+}
+
+func (p *Pool) enqueueLocked(ch string) {
+	p.pending[ch] = true
+	p.queue = append(p.queue, ch)
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *Pool) newMember() (*Bot, error) {
+	handle := func(m interfaces.Message, _ interfaces.Bot) {
+		if p.handleFunc != nil {
+			p.handleFunc(m, p)
+		}
+	}
+	var b *Bot
+	if p.opts.OAuth == "" {
+		b = NewAnonymous(handle)
+	} else {
+		b = New(p.opts.Name, p.opts.OAuth, handle)
+	}
+	b.SetDispatcher(p.dispatcher.Get())
+	b.noReconnect = true
+	b.onDisconnect = p.memberLost
+	if p.opts.Server != "" {
+		b.SetServer(p.opts.Server)
+	}
+	if p.opts.Transport != nil {
+		b.SetTransport(p.opts.Transport)
+	}
+	if err := b.Connect(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// memberLost moves channels of dropped connection to the queue
+func (p *Pool) memberLost(lost *Bot) {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	for i, b := range p.members {
+		if b == lost {
+			p.members = append(p.members[:i:i], p.members[i+1:]...)
+			break
+		}
+	}
+	for ch, b := range p.channels {
+		if b == lost {
+			delete(p.channels, ch)
+			p.enqueueLocked(ch)
+		}
+	}
+}
+
+func (p *Pool) joinLoop() {
+	for {
+		ch, ok := p.next()
+		if !ok {
+			return
+		}
+		if !p.waitJoin() {
+			return
+		}
+		if err := p.join(ch); err != nil {
+			log.Println("twitch pool:", err)
+			p.locker.Lock()
+			if p.pending[ch] {
+				p.queue = append(p.queue, ch)
+			}
+			p.locker.Unlock()
+			select {
+			case <-time.After(time.Second):
+			case <-p.stop:
+				return
+			}
+		}
+	}
+}
+
+// next blocks until channel is queued, false when pool is stopped
+func (p *Pool) next() (string, bool) {
+	for {
+		p.locker.Lock()
+		for len(p.queue) > 0 {
+			ch := p.queue[0]
+			p.queue = p.queue[1:]
+			if p.pending[ch] {
+				p.locker.Unlock()
+				return ch, true
+			}
+		}
+		p.locker.Unlock()
+		select {
+		case <-p.wake:
+		case <-p.stop:
+			return "", false
+		}
+	}
+}
+
+// waitJoin sleeps until JOIN fits into JoinRate per JoinWindow
+func (p *Pool) waitJoin() bool {
+	if len(p.joins) >= p.opts.JoinRate {
+		wait := p.joins[0].Add(p.opts.JoinWindow).Sub(time.Now())
+		if wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-p.stop:
+				return false
+			}
+		}
+		p.joins = p.joins[1:]
+	}
+	p.joins = append(p.joins, time.Now())
+	return true
+}
+
+// join sends JOIN on least loaded connection, new connection is opened if all are full
+func (p *Pool) join(ch string) error {
+	p.locker.Lock()
+	if !p.pending[ch] {
+		p.locker.Unlock()
+		return nil
+	}
+	b := p.leastLoadedLocked()
+	p.locker.Unlock()
+	if b == nil {
+		var err error
+		b, err = p.newMember()
+		if err != nil {
+			return err
+		}
+		p.locker.Lock()
+		p.members = append(p.members, b)
+		p.locker.Unlock()
+	}
+	if err := b.Join(ch); err != nil {
+		return err
+	}
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	if !p.pending[ch] {
+		// left while joining
+		go b.Leave(ch)
+		return nil
+	}
+	delete(p.pending, ch)
+	p.channels[ch] = b
+	return nil
+}
+
+func (p *Pool) leastLoadedLocked() *Bot {
+	count := map[*Bot]int{}
+	for _, b := range p.channels {
+		count[b]++
+	}
+	var best *Bot
+	for _, b := range p.members {
+		if count[b] >= p.opts.ChannelsPerConn {
+			continue
+		}
+		if best == nil || count[b] < count[best] {
+			best = b
+		}
+	}
+	return best
+}
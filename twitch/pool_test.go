@@ -0,0 +1,104 @@
+package twitch_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FireGM/chats/chatstest"
+	"github.com/FireGM/chats/twitch"
+)
+
+func waitUntil(t *testing.T, what string, f func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !f() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func joins(s *chatstest.TwitchServer) int {
+	n := 0
+	for _, line := range s.Received() {
+		if strings.HasPrefix(line, "JOIN ") {
+			n++
+		}
+	}
+	return n
+}
+
+func TestPool(t *testing.T) {
+	s, err := chatstest.NewTwitchServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	p := twitch.NewPool(twitch.PoolOptions{Server: s.Addr(), ChannelsPerConn: 2, JoinRate: 100}, nil)
+	if err := p.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Disconnect()
+	for _, ch := range []string{"a", "b", "c", "d", "e"} {
+		p.Join(ch)
+	}
+	waitUntil(t, "joins", func() bool { return p.Pending() == 0 })
+	if got := p.Connections(); got != 3 {
+		t.Errorf("Connections() = %v, want %v", got, 3)
+	}
+
+	s.DisconnectAll()
+	waitUntil(t, "rejoins", func() bool { return joins(s) == 10 && p.Pending() == 0 })
+	if got := p.Connections(); got != 3 {
+		t.Errorf("Connections() after drop = %v, want %v", got, 3)
+	}
+}
+
+func TestPoolPacing(t *testing.T) {
+	s, err := chatstest.NewTwitchServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	p := twitch.NewPool(twitch.PoolOptions{Server: s.Addr(), JoinRate: 2, JoinWindow: 200 * time.Millisecond}, nil)
+	if err := p.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Disconnect()
+	start := time.Now()
+	for _, ch := range []string{"a", "b", "c", "d", "e"} {
+		p.Join(ch)
+	}
+	waitUntil(t, "joins", func() bool { return p.Pending() == 0 })
+	if d := time.Since(start); d < 400*time.Millisecond {
+		t.Errorf("5 joins with rate 2 per 200ms took %v, want at least 400ms", d)
+	}
+}
+
+func TestPoolChannelNames(t *testing.T) {
+	s, err := chatstest.NewTwitchServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	p := twitch.NewPool(twitch.PoolOptions{Server: s.Addr(), JoinRate: 100}, nil)
+	if err := p.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Disconnect()
+	for _, ch := range []string{"#Chan", "chan", "CHAN"} {
+		p.Join(ch)
+	}
+	waitUntil(t, "joins", func() bool { return p.Pending() == 0 && joins(s) == 1 })
+	time.Sleep(50 * time.Millisecond)
+	if got := joins(s); got != 1 {
+		t.Errorf("JOIN sent %v times, want %v", got, 1)
+	}
+	if err := p.Leave("#CHAN"); err != nil {
+		t.Fatal(err)
+	}
+	if !s.WaitFor("PART #chan", 5*time.Second) {
+		t.Errorf("PART #chan is not sent")
+	}
+}
@@ -0,0 +1,132 @@
+package twitch
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/FireGM/chats/dispatch"
+	"github.com/FireGM/chats/interfaces"
+)
+
+// PresenceEvent is user who joined or left channel
+type PresenceEvent struct {
+	Channel string
+	User    string
+	Joined  bool
+}
+
+// presence is chatters of channels built from NAMES, JOIN and PART
+type presence struct {
+	chatters map[string]map[string]bool
+	// names collects 353 until 366 ends list
+	names map[string]map[string]bool
+}
+
+// TrackPresence makes bot request membership capability and keep chatters of channels,
+// call it before Connect. Twitch sends JOIN and PART in batches every few seconds
+// and NAMES only for channels with less than 1000 chatters
+func (b *Bot) TrackPresence() {
+	b.presenceLocker.Lock()
+	defer b.presenceLocker.Unlock()
+	b.presence = &presence{chatters: map[string]map[string]bool{}, names: map[string]map[string]bool{}}
+}
+
+// OnPresence sets callback for joins and leaves of users, see TrackPresence
+func (b *Bot) OnPresence(f func(PresenceEvent, *Bot)) {
+	b.onPresence = f
+}
+
+// Chatters returns sorted logins of users in channel, bot itself is not included
+func (b *Bot) Chatters(ch string) []string {
+	b.presenceLocker.RLock()
+	defer b.presenceLocker.RUnlock()
+	if b.presence == nil {
+		return nil
+	}
+	var users []string
+	for user := range b.presence.chatters[channelName(ch)] {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	return users
+}
+
+func (b *Bot) tracksPresence() bool {
+	b.presenceLocker.RLock()
+	defer b.presenceLocker.RUnlock()
+	return b.presence != nil
+}
+
+// updatePresence applies NAMES, JOIN and PART, own PART forgets channel
+func (b *Bot) updatePresence(m interfaces.Message) {
+	var events []PresenceEvent
+	b.presenceLocker.Lock()
+	p := b.presence
+	if p == nil {
+		b.presenceLocker.Unlock()
+		return
+	}
+	switch m := m.(type) {
+	case *Message:
+		if m.Type != joinMsg && m.Type != partMsg {
+			break
+		}
+		user := strings.ToLower(m.User)
+		if strings.EqualFold(user, b.name) {
+			if m.Type == partMsg {
+				delete(p.chatters, m.Channel)
+			}
+			break
+		}
+		set := p.chatters[m.Channel]
+		if set == nil {
+			set = map[string]bool{}
+			p.chatters[m.Channel] = set
+		}
+		if m.Type == joinMsg && !set[user] {
+			set[user] = true
+			events = append(events, PresenceEvent{Channel: m.Channel, User: user, Joined: true})
+		} else if m.Type == partMsg && set[user] {
+			delete(set, user)
+			events = append(events, PresenceEvent{Channel: m.Channel, User: user})
+		}
+	case *Numeric:
+		switch m.Code {
+		case 353:
+			if p.names[m.Channel] == nil {
+				p.names[m.Channel] = map[string]bool{}
+			}
+			for _, user := range strings.Fields(m.Text) {
+				if user = strings.ToLower(user); user != strings.ToLower(b.name) {
+					p.names[m.Channel][user] = true
+				}
+			}
+		case 366:
+			// list replaces chatters, for example after reconnect
+			names := p.names[m.Channel]
+			delete(p.names, m.Channel)
+			old := p.chatters[m.Channel]
+			for user := range names {
+				if !old[user] {
+					events = append(events, PresenceEvent{Channel: m.Channel, User: user, Joined: true})
+				}
+			}
+			for user := range old {
+				if !names[user] {
+					events = append(events, PresenceEvent{Channel: m.Channel, User: user})
+				}
+			}
+			if names == nil {
+				names = map[string]bool{}
+			}
+			p.chatters[m.Channel] = names
+		}
+	}
+	b.presenceLocker.Unlock()
+	if f := b.onPresence; f != nil {
+		for _, e := range events {
+			e := e
+			b.dispatcher.Get().Dispatch(dispatch.Key(m), func() { f(e, b) })
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package twitch_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FireGM/chats/chatstest"
+	"github.com/FireGM/chats/twitch"
+)
+
+func TestBotPresence(t *testing.T) {
+	s, err := chatstest.NewTwitchServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.UserJoin("a", "early")
+	var locker sync.Mutex
+	var events []twitch.PresenceEvent
+	b := twitch.NewAnonymous(nil)
+	b.SetServer(s.Addr())
+	b.TrackPresence()
+	b.OnPresence(func(e twitch.PresenceEvent, _ *twitch.Bot) {
+		locker.Lock()
+		events = append(events, e)
+		locker.Unlock()
+	})
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+	b.JoinAndWait("a", time.Second)
+	waitUntil(t, "names", func() bool { return len(b.Chatters("a")) == 1 })
+
+	s.UserJoin("a", "viewer")
+	s.UserJoin("a", "other")
+	s.UserPart("a", "early")
+	waitUntil(t, "events", func() bool {
+		locker.Lock()
+		defer locker.Unlock()
+		return len(events) == 4
+	})
+	if got, want := b.Chatters("#A"), []string{"other", "viewer"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Chatters() = %v, want %v", got, want)
+	}
+	locker.Lock()
+	want := []twitch.PresenceEvent{
+		{Channel: "a", User: "early", Joined: true},
+		{Channel: "a", User: "viewer", Joined: true},
+		{Channel: "a", User: "other", Joined: true},
+		{Channel: "a", User: "early"},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+	locker.Unlock()
+
+	b.Leave("a")
+	waitUntil(t, "leave", func() bool { return len(b.Chatters("a")) == 0 })
+}
@@ -0,0 +1,252 @@
+package twitch_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FireGM/chats/chatstest"
+	"github.com/FireGM/chats/interfaces"
+	"github.com/FireGM/chats/twitch"
+)
+
+func TestBotReconnect(t *testing.T) {
+	s, err := chatstest.NewTwitchServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	var locker sync.Mutex
+	var got []string
+	b := twitch.NewAnonymous(func(m interfaces.Message, _ interfaces.Bot) {
+		if m.IsFromUser() {
+			locker.Lock()
+			got = append(got, m.GetTextMessage())
+			locker.Unlock()
+		}
+	})
+	b.SetServer(s.Addr())
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+	b.Join("a")
+	waitUntil(t, "join", func() bool { return joins(s) == 1 })
+
+	s.Reconnect()
+	waitUntil(t, "new connection", func() bool { return joins(s) == 2 })
+	// both connections get message during migration
+	s.Privmsg("a", "u", "hello")
+	waitUntil(t, "old connection closed", func() bool { return s.Connections() == 1 })
+	s.Privmsg("a", "u", "after")
+	waitUntil(t, "messages", func() bool {
+		locker.Lock()
+		defer locker.Unlock()
+		return len(got) >= 2
+	})
+	time.Sleep(50 * time.Millisecond)
+	locker.Lock()
+	defer locker.Unlock()
+	if len(got) != 2 || got[0] != "hello" || got[1] != "after" {
+		t.Errorf("messages = %q, want %q", got, []string{"hello", "after"})
+	}
+}
+
+func TestBotKeepalive(t *testing.T) {
+	// logins on muted server fail fast
+	defer twitch.SetLoginTimeout(100 * time.Millisecond)()
+	s, err := chatstest.NewTwitchServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	got := make(chan string, 10)
+	b := twitch.NewAnonymous(func(m interfaces.Message, _ interfaces.Bot) {
+		if m.IsFromUser() {
+			got <- m.GetTextMessage()
+		}
+	})
+	b.SetServer(s.Addr())
+	b.SetKeepalive(50*time.Millisecond, 50*time.Millisecond)
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+	b.Join("a")
+	waitUntil(t, "join", func() bool { return joins(s) == 1 })
+
+	s.Mute(true)
+	if !s.WaitFor("PING", 5*time.Second) {
+		t.Fatal("bot doesn't send PING")
+	}
+	waitUntil(t, "new connection after dead one", func() bool {
+		n := 0
+		for _, line := range s.Received() {
+			if strings.HasPrefix(line, "NICK ") {
+				n++
+			}
+		}
+		return n >= 2
+	})
+	s.Mute(false)
+	waitUntil(t, "rejoin after dead connection", func() bool { return joins(s) >= 2 })
+	time.Sleep(300 * time.Millisecond)
+	s.Privmsg("a", "u", "alive")
+	select {
+	case text := <-got:
+		if text != "alive" {
+			t.Errorf("message = %q, want %q", text, "alive")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("no message after reconnect")
+	}
+}
+
+func TestBotMembership(t *testing.T) {
+	s, err := chatstest.NewTwitchServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	b := twitch.NewAnonymous(nil)
+	b.SetServer(s.Addr())
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+
+	if err := b.JoinAndWait("Lirik", 5*time.Second); err != nil {
+		t.Fatalf("JoinAndWait() = %v", err)
+	}
+	info := b.Channels()["lirik"]
+	if info.State != twitch.ChannelJoined || info.JoinedAt.IsZero() {
+		t.Errorf("Channels()[lirik] = %+v, want joined", info)
+	}
+
+	s.Suspend("suspended")
+	err = b.JoinAndWait("suspended", 5*time.Second)
+	if je, ok := err.(*twitch.JoinError); !ok || je.MsgID != "msg_channel_suspended" {
+		t.Errorf("JoinAndWait(suspended) = %v, want JoinError", err)
+	}
+
+	b.Leave("lirik")
+	if !s.WaitFor("PART #lirik", 5*time.Second) {
+		t.Fatal("Leave() doesn't send PART")
+	}
+	waitUntil(t, "part echo", func() bool {
+		_, ok := b.Channels()["lirik"]
+		return !ok
+	})
+
+	// join right after leave is not lost while PART is not confirmed
+	if err := b.JoinAndWait("lirik", 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	b.Leave("lirik")
+	if err := b.JoinAndWait("lirik", 5*time.Second); err != nil {
+		t.Fatalf("JoinAndWait() after Leave() = %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if info := b.Channels()["lirik"]; info.State != twitch.ChannelJoined {
+		t.Errorf("Channels()[lirik] after part echo = %+v, want joined", info)
+	}
+	if got := joins(s); got != 4 {
+		t.Errorf("JOIN sent %v times, want %v", got, 4)
+	}
+}
+
+func TestBotHandlerTypes(t *testing.T) {
+	s, err := chatstest.NewTwitchServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	got := make(chan interfaces.Message, 20)
+	b := twitch.NewAnonymous(func(m interfaces.Message, _ interfaces.Bot) {
+		got <- m
+	})
+	b.SetServer(s.Addr())
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+	if err := b.JoinAndWait("a", 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// service lines are not passed to handler
+	s.SendToChannel("a", "@msg-id=slow_on :tmi.twitch.tv NOTICE #a :This room is now in slow mode.")
+	s.SendToChannel("a", ":tmi.twitch.tv HOSTTARGET #a :other 10")
+	s.SendToChannel("a", ":tmi.twitch.tv CAP * ACK :twitch.tv/tags")
+	s.SendToChannel("a", "@login=u;target-msg-id=m1 :tmi.twitch.tv CLEARMSG #a :hi")
+	s.Privmsg("a", "u", "hello")
+	deleted := false
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case m := <-got:
+			typ := m.(interface {
+				ToIRC() *twitch.IRCMessage
+			}).ToIRC().Command
+			switch typ {
+			case "NOTICE", "HOSTTARGET", "CAP", "GLOBALUSERSTATE", "001", "353", "366":
+				t.Errorf("handler got %v", typ)
+			}
+			if c, ok := m.(*twitch.ClearMsg); ok && c.DeletedMessageID() == "m1" {
+				deleted = true
+			}
+			if m.IsFromUser() {
+				if !deleted {
+					t.Error("handler didn't get CLEARMSG")
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatal("timeout waiting for message")
+		}
+	}
+}
+
+func TestBotWhisper(t *testing.T) {
+	s, err := chatstest.NewTwitchServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	handled := make(chan interfaces.Message, 10)
+	whispers := make(chan *twitch.Whisper, 1)
+	b := twitch.New("bot", "token", func(m interfaces.Message, _ interfaces.Bot) {
+		handled <- m
+	})
+	b.OnWhisper(func(w *twitch.Whisper, _ *twitch.Bot) {
+		whispers <- w
+	})
+	b.SetServer(s.Addr())
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+	if err := b.JoinAndWait("a", 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Whisper("fan", "bot", "secret")
+	select {
+	case w := <-whispers:
+		if w.User != "fan" || w.Text != "secret" || w.IsFromUser() {
+			t.Errorf("whisper = %v: %v, IsFromUser() = %v", w.User, w.Text, w.IsFromUser())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for whisper")
+	}
+	s.Privmsg("a", "u", "public")
+	for m := range handled {
+		if _, ok := m.(*twitch.Whisper); ok {
+			t.Fatal("handler got whisper")
+		}
+		if m.GetTextMessage() == "public" {
+			break
+		}
+	}
+}
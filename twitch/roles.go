@@ -0,0 +1,68 @@
+package twitch
+
+import "strconv"
+
+// IsBroadcaster reports message from owner of channel.
+// Role helpers use only tags of message, they never request api
+func (m *Message) IsBroadcaster() bool {
+	if _, ok := m.Badges["broadcaster"]; ok {
+		return true
+	}
+	return m.RoomID != 0 && m.Tags["user-id"] == strconv.Itoa(m.RoomID)
+}
+
+// IsVIP checks vip badge and vip tag, twitch sends tag when badge is hidden
+func (m *Message) IsVIP() bool {
+	if _, ok := m.Badges["vip"]; ok {
+		return true
+	}
+	return m.Tags["vip"] == "1"
+}
+
+// IsFounder reports one of first subscribers of channel
+func (m *Message) IsFounder() bool {
+	_, ok := m.Badges["founder"]
+	return ok
+}
+
+// IsStaff reports twitch staff or admin
+func (m *Message) IsStaff() bool {
+	_, staff := m.Badges["staff"]
+	_, admin := m.Badges["admin"]
+	return staff || admin || m.Tags["user-type"] == "staff" || m.Tags["user-type"] == "admin"
+}
+
+// IsTurbo reports user with turbo
+func (m *Message) IsTurbo() bool {
+	if _, ok := m.Badges["turbo"]; ok {
+		return true
+	}
+	return m.Tags["turbo"] == "1"
+}
+
+// IsFirstMessage reports first message of user in channel
+func (m *Message) IsFirstMessage() bool {
+	return m.Tags["first-msg"] == "1"
+}
+
+// IsReturningChatter reports user who came back after long time
+func (m *Message) IsReturningChatter() bool {
+	return m.Tags["returning-chatter"] == "1"
+}
+
+// IsPrivileged is broadcaster, moderator or VIP, automod usually skips them
+func (m *Message) IsPrivileged() bool {
+	mod, _ := m.IsModerator()
+	return mod || m.IsBroadcaster() || m.IsVIP()
+}
+
+// SubscriberMonths returns exact months of subscription from badge-info, 0 if user is not subscriber
+func (m *Message) SubscriberMonths() int {
+	info := getBadges(m.Tags["badge-info"])
+	v, ok := info["subscriber"]
+	if !ok {
+		v = info["founder"]
+	}
+	months, _ := strconv.Atoi(v)
+	return months
+}
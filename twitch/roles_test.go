@@ -0,0 +1,80 @@
+package twitch
+
+import "testing"
+
+func TestMessage_Roles(t *testing.T) {
+	const prefix = ":u!u@u.tmi.twitch.tv PRIVMSG #chan :hi"
+	tests := []struct {
+		name string
+		line string
+		want map[string]bool
+		subs int
+	}{
+		{
+			name: "broadcaster",
+			line: "@badge-info=;badges=broadcaster/1;mod=0;room-id=10;user-id=10 " + prefix,
+			want: map[string]bool{"broadcaster": true, "privileged": true},
+		},
+		{
+			name: "broadcaster by user-id",
+			line: "@badges=;room-id=10;user-id=10 " + prefix,
+			want: map[string]bool{"broadcaster": true, "privileged": true},
+		},
+		{
+			name: "vip and moderator",
+			line: "@badges=moderator/1,vip/1;mod=1;room-id=10;user-id=11 " + prefix,
+			want: map[string]bool{"moderator": true, "vip": true, "privileged": true},
+		},
+		{
+			name: "vip tag without badge",
+			line: "@badges=;vip=1;room-id=10;user-id=11 " + prefix,
+			want: map[string]bool{"vip": true, "privileged": true},
+		},
+		{
+			name: "subscriber with months",
+			line: "@badge-info=subscriber/14;badges=subscriber/12,turbo/1;subscriber=1;turbo=1;room-id=10 " + prefix,
+			want: map[string]bool{"subscriber": true, "turbo": true},
+			subs: 14,
+		},
+		{
+			name: "founder",
+			line: "@badge-info=founder/30;badges=founder/0;subscriber=0;room-id=10 " + prefix,
+			want: map[string]bool{"subscriber": true, "founder": true},
+			subs: 30,
+		},
+		{
+			name: "staff",
+			line: "@badges=staff/1;user-type=staff;room-id=10 " + prefix,
+			want: map[string]bool{"staff": true},
+		},
+		{
+			name: "first message",
+			line: "@badges=;first-msg=1;returning-chatter=0;room-id=10 " + prefix,
+			want: map[string]bool{"first": true},
+		},
+		{
+			name: "returning chatter",
+			line: "@badges=;first-msg=0;returning-chatter=1;room-id=10 " + prefix,
+			want: map[string]bool{"returning": true},
+		},
+	}
+	for _, tt := range tests {
+		m, err := ParseMessage(tt.line)
+		if err != nil {
+			t.Fatalf("%q. ParseMessage() error = %v", tt.name, err)
+		}
+		mod, _ := m.IsModerator()
+		sub, _ := m.IsSubscriber()
+		got := map[string]bool{"broadcaster": m.IsBroadcaster(), "moderator": mod, "vip": m.IsVIP(),
+			"subscriber": sub, "founder": m.IsFounder(), "staff": m.IsStaff(), "turbo": m.IsTurbo(),
+			"first": m.IsFirstMessage(), "returning": m.IsReturningChatter(), "privileged": m.IsPrivileged()}
+		for role, v := range got {
+			if v != tt.want[role] {
+				t.Errorf("%q. %s = %v, want %v", tt.name, role, v, tt.want[role])
+			}
+		}
+		if got := m.SubscriberMonths(); got != tt.subs {
+			t.Errorf("%q. Message.SubscriberMonths() = %v, want %v", tt.name, got, tt.subs)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package twitch
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/FireGM/chats/dispatch"
+)
+
+// RoomState is chat mode of channel from ROOMSTATE
+type RoomState struct {
+	Channel   string `json:"channel"`
+	RoomID    int    `json:"room_id"`
+	EmoteOnly bool   `json:"emote_only"`
+	// FollowersOnly is minutes of following needed for chatting, -1 when disabled
+	FollowersOnly int  `json:"followers_only"`
+	R9K           bool `json:"r9k"`
+	// Slow is seconds between messages of user, 0 when disabled
+	Slow     int  `json:"slow"`
+	SubsOnly bool `json:"subs_only"`
+}
+
+// apply sets only modes present in tags, ROOMSTATE after change has only changed tag
+func (r *RoomState) apply(m *Message) {
+	if m.RoomID != 0 {
+		r.RoomID = m.RoomID
+	}
+	for key, value := range m.Tags {
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			continue
+		}
+		switch key {
+		case "emote-only":
+			r.EmoteOnly = v == 1
+		case "followers-only":
+			r.FollowersOnly = v
+		case "r9k":
+			r.R9K = v == 1
+		case "slow":
+			r.Slow = v
+		case "subs-only":
+			r.SubsOnly = v == 1
+		}
+	}
+}
+
+// RoomState returns last known chat mode of joined channel
+func (b *Bot) RoomState(ch string) (RoomState, bool) {
+	b.roomsLocker.RLock()
+	defer b.roomsLocker.RUnlock()
+	r, ok := b.rooms[channelName(ch)]
+	if !ok {
+		return RoomState{}, false
+	}
+	return *r, true
+}
+
+// OnRoomState sets callback for changes of chat mode, for first ROOMSTATE after join old is zero
+func (b *Bot) OnRoomState(f func(old, new RoomState, b *Bot)) {
+	b.onRoomState = f
+}
+
+func (b *Bot) updateRoomState(m *Message) {
+	b.roomsLocker.Lock()
+	if b.rooms == nil {
+		b.rooms = map[string]*RoomState{}
+	}
+	r, ok := b.rooms[m.Channel]
+	if !ok {
+		r = &RoomState{Channel: m.Channel, FollowersOnly: -1}
+		b.rooms[m.Channel] = r
+	}
+	old := *r
+	r.apply(m)
+	updated := *r
+	b.roomsLocker.Unlock()
+	if !ok {
+		old = RoomState{}
+	}
+	if b.onRoomState != nil && (!ok || old != updated) {
+		b.dispatcher.Get().Dispatch(dispatch.Key(m), func() { b.onRoomState(old, updated, b) })
+	}
+}
+
+func (b *Bot) forgetRoomState(ch string) {
+	b.roomsLocker.Lock()
+	defer b.roomsLocker.Unlock()
+	delete(b.rooms, ch)
+}
+
+// SetSlowMode sets seconds between messages of user, 0 disables slow mode
+func (b *Bot) SetSlowMode(ch string, seconds int) error {
+	if b.anonymous {
+		return ErrAnonymous
+	}
+	if b.helix != nil {
+		settings := ChatSettings{SlowMode: Bool(seconds > 0)}
+		if seconds > 0 {
+			settings.SlowModeWaitTime = Int(seconds)
+		}
+		return b.updateChatSettings(ch, settings)
+	}
+	if seconds <= 0 {
+		return b.SendMessageToChan(ch, ".slowoff")
+	}
+	return b.SendMessageToChan(ch, fmt.Sprintf(".slow %d", seconds))
+}
+
+func (b *Bot) SetEmoteOnly(ch string, on bool) error {
+	if b.anonymous {
+		return ErrAnonymous
+	}
+	if b.helix != nil {
+		return b.updateChatSettings(ch, ChatSettings{EmoteMode: Bool(on)})
+	}
+	if on {
+		return b.SendMessageToChan(ch, ".emoteonly")
+	}
+	return b.SendMessageToChan(ch, ".emoteonlyoff")
+}
+
+// SetFollowersOnly sets minutes of following needed for chatting, negative disables mode
+func (b *Bot) SetFollowersOnly(ch string, minutes int) error {
+	if b.anonymous {
+		return ErrAnonymous
+	}
+	if b.helix != nil {
+		settings := ChatSettings{FollowerMode: Bool(minutes >= 0)}
+		if minutes >= 0 {
+			settings.FollowerModeDuration = Int(minutes)
+		}
+		return b.updateChatSettings(ch, settings)
+	}
+	if minutes < 0 {
+		return b.SendMessageToChan(ch, ".followersoff")
+	}
+	return b.SendMessageToChan(ch, fmt.Sprintf(".followers %dm", minutes))
+}
+
+func (b *Bot) SetSubsOnly(ch string, on bool) error {
+	if b.anonymous {
+		return ErrAnonymous
+	}
+	if b.helix != nil {
+		return b.updateChatSettings(ch, ChatSettings{SubscriberMode: Bool(on)})
+	}
+	if on {
+		return b.SendMessageToChan(ch, ".subscribers")
+	}
+	return b.SendMessageToChan(ch, ".subscribersoff")
+}
+
+// SetUniqueChat enables r9k mode
+func (b *Bot) SetUniqueChat(ch string, on bool) error {
+	if b.anonymous {
+		return ErrAnonymous
+	}
+	if b.helix != nil {
+		return b.updateChatSettings(ch, ChatSettings{UniqueChatMode: Bool(on)})
+	}
+	if on {
+		return b.SendMessageToChan(ch, ".uniquechat")
+	}
+	return b.SendMessageToChan(ch, ".uniquechatoff")
+}
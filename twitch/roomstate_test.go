@@ -0,0 +1,133 @@
+package twitch_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FireGM/chats/chatstest"
+	"github.com/FireGM/chats/twitch"
+)
+
+type roomChange struct {
+	old, new twitch.RoomState
+}
+
+func TestBotRoomState(t *testing.T) {
+	s, err := chatstest.NewTwitchServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	b := twitch.New("bot", "token", nil)
+	b.SetServer(s.Addr())
+	var changes []roomChange
+	var locker sync.Mutex
+	b.OnRoomState(func(old, new twitch.RoomState, b *twitch.Bot) {
+		locker.Lock()
+		defer locker.Unlock()
+		changes = append(changes, roomChange{old, new})
+	})
+	list := func() []roomChange {
+		locker.Lock()
+		defer locker.Unlock()
+		return append([]roomChange(nil), changes...)
+	}
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+	if err := b.JoinAndWait("chan", 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "first roomstate", func() bool { return len(list()) == 1 })
+	initial := twitch.RoomState{Channel: "chan", RoomID: 1, FollowersOnly: -1}
+	if c := list()[0]; c.old != (twitch.RoomState{}) || c.new != initial {
+		t.Errorf("first change = %+v, want zero -> %+v", c, initial)
+	}
+
+	// change has only changed tag, other modes are kept
+	s.SendToChannel("chan", "@room-id=1;slow=30 :tmi.twitch.tv ROOMSTATE #chan")
+	s.SendToChannel("chan", "@room-id=1;slow=30 :tmi.twitch.tv ROOMSTATE #chan")
+	s.SendToChannel("chan", "@emote-only=1;room-id=1 :tmi.twitch.tv ROOMSTATE #chan")
+	waitUntil(t, "changes", func() bool { return len(list()) == 3 })
+	slow := initial
+	slow.Slow = 30
+	emote := slow
+	emote.EmoteOnly = true
+	if c := list()[1]; c.old != initial || c.new != slow {
+		t.Errorf("slow change = %+v, want %+v -> %+v", c, initial, slow)
+	}
+	if c := list()[2]; c.old != slow || c.new != emote {
+		t.Errorf("emote change = %+v, want %+v -> %+v", c, slow, emote)
+	}
+	for _, ch := range []string{"chan", "#Chan"} {
+		if r, ok := b.RoomState(ch); !ok || r != emote {
+			t.Errorf("RoomState(%q) = %+v, %v, want %+v", ch, r, ok, emote)
+		}
+	}
+	if _, ok := b.RoomState("other"); ok {
+		t.Errorf("RoomState(%q) is found", "other")
+	}
+}
+
+func TestBotRoomStateSetters(t *testing.T) {
+	s, err := chatstest.NewTwitchServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	b := twitch.New("bot", "token", nil)
+	b.SetServer(s.Addr())
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+
+	tests := []struct {
+		set  func() error
+		want string
+	}{
+		{func() error { return b.SetSlowMode("chan", 10) }, "PRIVMSG #chan :.slow 10"},
+		{func() error { return b.SetSlowMode("chan", 0) }, "PRIVMSG #chan :.slowoff"},
+		{func() error { return b.SetEmoteOnly("chan", true) }, "PRIVMSG #chan :.emoteonly"},
+		{func() error { return b.SetFollowersOnly("chan", 10) }, "PRIVMSG #chan :.followers 10m"},
+		{func() error { return b.SetFollowersOnly("chan", -1) }, "PRIVMSG #chan :.followersoff"},
+		{func() error { return b.SetSubsOnly("chan", false) }, "PRIVMSG #chan :.subscribersoff"},
+		{func() error { return b.SetUniqueChat("chan", true) }, "PRIVMSG #chan :.uniquechat"},
+	}
+	for _, tt := range tests {
+		if err := tt.set(); err != nil {
+			t.Fatal(err)
+		}
+		if !s.WaitFor(tt.want, 5*time.Second) {
+			t.Errorf("%q is not sent", tt.want)
+		}
+	}
+
+	hs, h, done := newHelix()
+	defer done()
+	defer twitch.SetHelix(nil)
+	hs.AddUser("1", "chan")
+	hs.AddUser("2", "bot")
+	b.SetHelix(h)
+	if err := b.SetFollowersOnly("chan", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetSubsOnly("chan", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetSlowMode("chan", 0); err != nil {
+		t.Fatal(err)
+	}
+	st := hs.Settings("1")
+	if st.FollowerMode == nil || !*st.FollowerMode || *st.FollowerModeDuration != 0 {
+		t.Errorf("follower mode = %+v, want on with 0 minutes", st)
+	}
+	if st.SubscriberMode == nil || !*st.SubscriberMode {
+		t.Errorf("subscriber mode = %+v, want on", st)
+	}
+	if st.SlowMode == nil || *st.SlowMode || st.SlowModeWaitTime != nil {
+		t.Errorf("slow mode = %+v, want off", st)
+	}
+}
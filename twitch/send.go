@@ -0,0 +1,223 @@
+package twitch
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FireGM/chats/dispatch"
+	"github.com/FireGM/chats/interfaces"
+)
+
+// sendTimeout is wait of USERSTATE or NOTICE after PRIVMSG
+var sendTimeout = 5 * time.Second
+
+// ErrSendTimeout is result of message without answer of server, it may be dropped silently
+var ErrSendTimeout = errors.New("twitch: no answer on send")
+
+var errSendCommand = errors.New("twitch: commands like .ban have no send result, use SendMessageToChan")
+
+// msg-id of NOTICE about rejected PRIVMSG
+const (
+	MsgRateLimit     = "msg_ratelimit"
+	MsgBanned        = "msg_banned"
+	MsgDuplicate     = "msg_duplicate"
+	MsgSlowMode      = "msg_slowmode"
+	MsgFollowersOnly = "msg_followersonly"
+	MsgSubsOnly      = "msg_subsonly"
+	MsgEmoteOnly     = "msg_emoteonly"
+	MsgR9K           = "msg_r9k"
+	MsgTimedOut      = "msg_timedout"
+)
+
+var sendFailures = map[string]bool{
+	MsgRateLimit: true, MsgBanned: true, MsgDuplicate: true, MsgSlowMode: true, MsgFollowersOnly: true,
+	"msg_followersonly_zero": true, "msg_followersonly_followed": true, MsgSubsOnly: true,
+	MsgEmoteOnly: true, MsgR9K: true, MsgTimedOut: true, "msg_suspended": true, "msg_verified_email": true,
+	"msg_requires_verified_phone_number": true, "msg_rejected": true, "msg_rejected_mandatory": true,
+	"msg_banned_email_alias": true,
+}
+
+// SendError is PRIVMSG rejected by server with NOTICE
+type SendError struct {
+	Channel string
+	MsgID   string
+	Text    string
+	// RetryAfter is wait from text of slow mode and timeout, 0 if unknown
+	RetryAfter time.Duration
+}
+
+func (e *SendError) Error() string {
+	return "twitch: message to #" + e.Channel + " is not sent: " + e.MsgID + ": " + e.Text
+}
+
+// Temporary is true when message can be sent again later
+func (e *SendError) Temporary() bool {
+	switch e.MsgID {
+	case MsgRateLimit, MsgDuplicate, MsgSlowMode, MsgTimedOut:
+		return true
+	}
+	return false
+}
+
+// SendResult is result of PRIVMSG, Err is nil, *SendError or ErrSendTimeout
+type SendResult struct {
+	Channel string
+	Text    string
+	Err     error
+}
+
+type pendingSend struct {
+	channel string
+	text    string
+	done    chan error
+	timer   *time.Timer
+}
+
+// OnSendResult sets callback for result of every message sent by SendMessageToChan or Reply
+func (b *Bot) OnSendResult(f func(SendResult, *Bot)) {
+	b.onSendResult = f
+}
+
+// SendAndWait sends message and waits for answer of server, rejected message is *SendError.
+// Commands other than /me are not accepted
+func (b *Bot) SendAndWait(ch, message string, timeout time.Duration) error {
+	if b.anonymous {
+		return ErrAnonymous
+	}
+	if !tracked(message) {
+		return errSendCommand
+	}
+	ch = channelName(ch)
+	p := b.trackSend(ch, message)
+	if err := b.Send(fmt.Sprintf("PRIVMSG #%s :%s", ch, message)); err != nil {
+		b.finishSend(p, err)
+		return err
+	}
+	select {
+	case err := <-p.done:
+		return err
+	case <-time.After(timeout):
+		return ErrSendTimeout
+	}
+}
+
+// tracked is false for commands like .ban, server answers them without USERSTATE.
+// /me is answered as usual message
+func tracked(message string) bool {
+	if !strings.HasPrefix(message, ".") && !strings.HasPrefix(message, "/") {
+		return true
+	}
+	return strings.HasPrefix(message[1:], "me ")
+}
+
+// sendPrivmsg sends line, only tracked messages get SendResult
+func (b *Bot) sendPrivmsg(ch, message, line string) error {
+	if !tracked(message) {
+		return b.Send(line)
+	}
+	p := b.trackSend(ch, message)
+	err := b.Send(line)
+	if err != nil {
+		b.finishSend(p, err)
+	}
+	return err
+}
+
+func (b *Bot) trackSend(ch, message string) *pendingSend {
+	p := &pendingSend{channel: channelName(ch), text: message, done: make(chan error, 1)}
+	b.sendsLocker.Lock()
+	if b.sends == nil {
+		b.sends = map[string][]*pendingSend{}
+	}
+	b.sends[p.channel] = append(b.sends[p.channel], p)
+	p.timer = time.AfterFunc(sendTimeout, func() { b.finishSend(p, ErrSendTimeout) })
+	b.sendsLocker.Unlock()
+	return p
+}
+
+// finishSend removes pending send and reports result once
+func (b *Bot) finishSend(p *pendingSend, err error) {
+	b.sendsLocker.Lock()
+	queue := b.sends[p.channel]
+	found := false
+	for i, q := range queue {
+		if q == p {
+			b.sends[p.channel] = append(queue[:i:i], queue[i+1:]...)
+			found = true
+			break
+		}
+	}
+	b.sendsLocker.Unlock()
+	if !found {
+		return
+	}
+	p.timer.Stop()
+	p.done <- err
+	if f := b.onSendResult; f != nil {
+		r := SendResult{Channel: p.channel, Text: p.text, Err: err}
+		b.dispatcher.Get().Dispatch(dispatch.Key(&Message{Channel: p.channel}), func() { f(r, b) })
+	}
+}
+
+// oldestSend returns first message without answer in channel
+func (b *Bot) oldestSend(ch string) *pendingSend {
+	b.sendsLocker.Lock()
+	defer b.sendsLocker.Unlock()
+	if len(b.sends[ch]) == 0 {
+		return nil
+	}
+	return b.sends[ch][0]
+}
+
+// updateSends matches USERSTATE and NOTICE to messages in order they were sent
+func (b *Bot) updateSends(m interfaces.Message) {
+	switch m := m.(type) {
+	case *Message:
+		if m.Type != userStateMsg || !b.answersSend(m.Channel) {
+			return
+		}
+		if p := b.oldestSend(m.Channel); p != nil {
+			b.finishSend(p, nil)
+		}
+	case *Notice:
+		if !sendFailures[m.MsgID] {
+			return
+		}
+		if p := b.oldestSend(m.Channel); p != nil {
+			b.finishSend(p, &SendError{Channel: m.Channel, MsgID: m.MsgID, Text: m.Text,
+				RetryAfter: retryAfter(m.Text)})
+		}
+	}
+}
+
+// answersSend is true for USERSTATE which answers PRIVMSG, USERSTATE of join is skipped
+func (b *Bot) answersSend(ch string) bool {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	c, ok := b.channels[ch]
+	if !ok || c.info.State != ChannelJoined {
+		return false
+	}
+	if !c.userState {
+		c.userState = true
+		return false
+	}
+	return true
+}
+
+// retryAfter finds "N seconds" in text like "You will be able to talk again in 3 seconds."
+func retryAfter(text string) time.Duration {
+	fields := strings.Fields(text)
+	for i := 1; i < len(fields); i++ {
+		if !strings.HasPrefix(fields[i], "second") {
+			continue
+		}
+		if n, err := strconv.Atoi(fields[i-1]); err == nil {
+			return time.Duration(n) * time.Second
+		}
+	}
+	return 0
+}
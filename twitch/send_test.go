@@ -0,0 +1,142 @@
+package twitch_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FireGM/chats/chatstest"
+	"github.com/FireGM/chats/twitch"
+)
+
+func TestBotSendResult(t *testing.T) {
+	s, err := chatstest.NewTwitchServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	var locker sync.Mutex
+	var results []twitch.SendResult
+	b := twitch.New("bot", "token", nil)
+	b.SetServer(s.Addr())
+	b.OnSendResult(func(r twitch.SendResult, _ *twitch.Bot) {
+		locker.Lock()
+		results = append(results, r)
+		locker.Unlock()
+	})
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+	b.JoinAndWait("a", time.Second)
+
+	if err := b.SendAndWait("a", "hello", time.Second); err != nil {
+		t.Errorf("SendAndWait() error = %v, want nil", err)
+	}
+
+	s.Reject("a", twitch.MsgSlowMode,
+		"This room is in slow mode and you are sending messages too quickly. You will be able to talk again in 3 seconds.")
+	err = b.SendAndWait("a", "again", time.Second)
+	e, ok := err.(*twitch.SendError)
+	if !ok {
+		t.Fatalf("SendAndWait() error = %v, want *twitch.SendError", err)
+	}
+	if e.MsgID != twitch.MsgSlowMode || e.RetryAfter != 3*time.Second || !e.Temporary() {
+		t.Errorf("SendError = %+v, want slow mode for 3s", e)
+	}
+
+	s.Reject("a", twitch.MsgBanned, "You are permanently banned from talking in a.")
+	b.SendMessageToChan("a", "spam")
+	// commands are not tracked
+	b.SendMessageToChan("a", ".slow 10")
+	waitUntil(t, "results", func() bool {
+		locker.Lock()
+		defer locker.Unlock()
+		return len(results) == 3
+	})
+	locker.Lock()
+	defer locker.Unlock()
+	if results[0].Err != nil || results[0].Text != "hello" {
+		t.Errorf("result = %+v, want success of hello", results[0])
+	}
+	if e, ok := results[2].Err.(*twitch.SendError); !ok || e.MsgID != twitch.MsgBanned || e.Temporary() {
+		t.Errorf("result = %+v, want msg_banned", results[2])
+	}
+}
+
+func TestBotSendDuringJoin(t *testing.T) {
+	s, err := chatstest.NewTwitchServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	b := twitch.New("bot", "token", nil)
+	b.SetServer(s.Addr())
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+
+	// USERSTATE sent on join doesn't confirm message sent right after JOIN
+	s.Reject("b", twitch.MsgBanned, "You are permanently banned from talking in b.")
+	b.Join("b")
+	err = b.SendAndWait("#B", "early", time.Second)
+	if e, ok := err.(*twitch.SendError); !ok || e.MsgID != twitch.MsgBanned {
+		t.Errorf("SendAndWait() error = %v, want msg_banned", err)
+	}
+	if !s.WaitFor("PRIVMSG #b :early", time.Second) {
+		t.Errorf("PRIVMSG #b is not sent")
+	}
+	s.Reject("b", "", "")
+	if err := b.SendAndWait("b", "later", time.Second); err != nil {
+		t.Errorf("SendAndWait() error = %v, want nil", err)
+	}
+}
+
+func TestBotSendCommands(t *testing.T) {
+	s, err := chatstest.NewTwitchServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	var locker sync.Mutex
+	var results []twitch.SendResult
+	b := twitch.New("bot", "token", nil)
+	b.SetServer(s.Addr())
+	b.OnSendResult(func(r twitch.SendResult, _ *twitch.Bot) {
+		locker.Lock()
+		results = append(results, r)
+		locker.Unlock()
+	})
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+	b.JoinAndWait("a", time.Second)
+
+	if err := b.SendAndWait("a", ".slow 10", time.Second); err == nil {
+		t.Errorf("SendAndWait() of command = nil, want error")
+	}
+	// USERSTATE of /me belongs to /me, not to next message
+	b.SendMessageToChan("a", "/me waves")
+	b.SendMessageToChan("a", ".slow 10")
+	b.SendMessageToChan("a", "hello")
+	waitUntil(t, "results", func() bool {
+		locker.Lock()
+		defer locker.Unlock()
+		return len(results) == 2
+	})
+	locker.Lock()
+	if results[0].Text != "/me waves" || results[0].Err != nil || results[1].Text != "hello" || results[1].Err != nil {
+		t.Errorf("results = %+v, want success of /me waves and hello", results)
+	}
+	locker.Unlock()
+
+	// NOTICE about failed JOIN doesn't fail message
+	s.Suspend("s")
+	b.Join("s")
+	err = b.SendAndWait("s", "hi", 200*time.Millisecond)
+	if _, ok := err.(*twitch.SendError); ok {
+		t.Errorf("SendAndWait() to suspended channel = %v, want no SendError", err)
+	}
+}
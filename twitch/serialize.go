@@ -0,0 +1,182 @@
+package twitch
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/FireGM/chats/interfaces"
+)
+
+const serverPrefix = "tmi.twitch.tv"
+
+var errNotTwitch = errors.New("message is not from twitch")
+
+// userCommands have user in prefix, others are sent by server
+var userCommands = map[string]bool{privMsg: true, joinMsg: true, partMsg: true, whisperMsg: true}
+
+// String builds line of irc without "\r\n", tags are sorted and escaped
+func (m *IRCMessage) String() string {
+	var b bytes.Buffer
+	if len(m.Tags) > 0 {
+		keys := make([]string, 0, len(m.Tags))
+		for k := range m.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.WriteByte('@')
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteByte(';')
+			}
+			b.WriteString(k + "=" + escapeTagValue(m.Tags[k]))
+		}
+		b.WriteByte(' ')
+	}
+	if prefix := m.prefix(); prefix != "" {
+		b.WriteString(":" + prefix + " ")
+	}
+	b.WriteString(m.Command)
+	for _, p := range m.Params {
+		b.WriteString(" " + p)
+	}
+	if m.HasTrailing || m.Trailing != "" {
+		b.WriteString(" :" + m.Trailing)
+	}
+	return b.String()
+}
+
+func (m *IRCMessage) prefix() string {
+	if m.Prefix != "" || m.Name == "" {
+		return m.Prefix
+	}
+	prefix := m.Name
+	if m.User != "" {
+		prefix += "!" + m.User
+	}
+	if m.Host != "" {
+		prefix += "@" + m.Host
+	}
+	return prefix
+}
+
+// Serialize turns message parsed by Parse back into line of irc
+func Serialize(m interfaces.Message) (string, error) {
+	i, ok := m.(interface {
+		ToIRC() *IRCMessage
+	})
+	if !ok {
+		return "", errNotTwitch
+	}
+	return i.ToIRC().String(), nil
+}
+
+// ToIRC builds irc message, fields taken from tags by parser are added to tags again
+func (m *Message) ToIRC() *IRCMessage {
+	irc := &IRCMessage{Tags: m.ircTags(), Command: m.Type, Trailing: m.Text}
+	if userCommands[m.Type] && m.User != "" {
+		irc.Name, irc.User, irc.Host = m.User, m.User, m.User+"."+serverPrefix
+		irc.Prefix = irc.prefix()
+	} else if !isDigits(m.Type) && m.Type != pingMsg {
+		irc.Prefix = serverPrefix
+	}
+	switch {
+	case m.Channel != "":
+		irc.Params = []string{"#" + m.Channel}
+	case m.Type == noticeMsg:
+		irc.Params = []string{"*"}
+	}
+	switch m.Type {
+	case privMsg, noticeMsg, whisperMsg, clearSingleMsg:
+		irc.HasTrailing = true
+	}
+	return irc
+}
+
+// ToIRC of whisper has recipient instead of channel
+func (w *Whisper) ToIRC() *IRCMessage {
+	irc := w.Message.ToIRC()
+	irc.Params = []string{w.To}
+	return irc
+}
+
+// ToIRC of numeric reply keeps all params
+func (n *Numeric) ToIRC() *IRCMessage {
+	irc := n.Message.ToIRC()
+	irc.Prefix = serverPrefix
+	irc.Params = n.Params
+	irc.HasTrailing = n.Text != ""
+	return irc
+}
+
+func (m *Message) ircTags() map[string]string {
+	tags := make(map[string]string, len(m.Tags)+8)
+	for k, v := range m.Tags {
+		tags[k] = v
+	}
+	if m.Badges != nil {
+		tags["badges"] = joinBadges(m.Badges)
+		tags["mod"] = strconv.Itoa(m.Mod)
+	} else if m.Mod != 0 {
+		tags["mod"] = strconv.Itoa(m.Mod)
+	}
+	if m.Color != "" {
+		tags["color"] = m.Color
+	}
+	if m.DisplayName != "" {
+		tags["display-name"] = m.DisplayName
+	}
+	if m.Emotes != nil {
+		tags["emotes"] = joinEmotes(m.Emotes)
+	}
+	if m.Bits != 0 {
+		tags["bits"] = strconv.Itoa(m.Bits)
+	}
+	if m.RoomID != 0 {
+		tags["room-id"] = strconv.Itoa(m.RoomID)
+	}
+	if r := m.Reply; r != nil {
+		for k, v := range map[string]string{
+			"reply-parent-msg-id":            r.MsgID,
+			"reply-parent-user-id":           r.UserID,
+			"reply-parent-user-login":        r.UserLogin,
+			"reply-parent-display-name":      r.DisplayName,
+			"reply-parent-msg-body":          r.Body,
+			"reply-thread-parent-msg-id":     r.ThreadMsgID,
+			"reply-thread-parent-user-login": r.ThreadParentLogin,
+		} {
+			if v != "" {
+				tags[k] = v
+			}
+		}
+	}
+	return tags
+}
+
+func joinBadges(badges map[string]string) string {
+	list := make([]string, 0, len(badges))
+	for name, version := range badges {
+		list = append(list, name+"/"+version)
+	}
+	sort.Strings(list)
+	return strings.Join(list, ",")
+}
+
+// joinEmotes is inverse of getEmotions, emotes without positions are skipped
+func joinEmotes(emotes map[string]*Emote) string {
+	var list []string
+	for _, e := range emotes {
+		if len(e.Positions) == 0 {
+			continue
+		}
+		ps := make([]string, len(e.Positions))
+		for i, p := range e.Positions {
+			ps[i] = strconv.Itoa(p.Start) + "-" + strconv.Itoa(p.End)
+		}
+		list = append(list, e.ID+":"+strings.Join(ps, ","))
+	}
+	sort.Strings(list)
+	return strings.Join(list, "/")
+}
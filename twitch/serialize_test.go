@@ -0,0 +1,104 @@
+package twitch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSerialize(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{
+			name: "privmsg",
+			line: `@badge-info=subscriber/8;badges=subscriber/6,premium/1;color=#E1630E;display-name=Haunterxx;emotes=88:11-18,20-27/25:0-4;id=04de4e6b;mod=0;room-id=24991333;subscriber=1;tmi-sent-ts=1496852221750;turbo=0;user-id=39647543;user-type= :haunterxx!haunterxx@haunterxx.tmi.twitch.tv PRIVMSG #imaqtpie :Kappa hello PogChamp PogChamp`,
+		},
+		{
+			name: "reply with escaped tags",
+			line: `@badges=;color=;display-name=U;emotes=;id=1;mod=0;reply-parent-display-name=Other;reply-parent-msg-body=hi\sthere\:\;reply-parent-msg-id=abc;reply-parent-user-id=2;reply-parent-user-login=other;reply-thread-parent-msg-id=abc;reply-thread-parent-user-login=other;room-id=1 :u!u@u.tmi.twitch.tv PRIVMSG #chan :@other yes`,
+		},
+		{
+			name: "cheer",
+			line: `@badges=bits/100;bits=100;color=;display-name=U;emotes=;id=2;mod=0;room-id=1 :u!u@u.tmi.twitch.tv PRIVMSG #chan :cheer100`,
+		},
+		{
+			name: "clearchat",
+			line: `@ban-duration=10;ban-reason=;room-id=24991333;target-user-id=135206893 :tmi.twitch.tv CLEARCHAT #imaqtpie :edyzetg`,
+		},
+		{
+			name: "clear all chat",
+			line: `@room-id=1;tmi-sent-ts=1 :tmi.twitch.tv CLEARCHAT #chan`,
+		},
+		{
+			name: "clearmsg",
+			line: `@login=ronni;target-msg-id=abc-123-def;tmi-sent-ts=1642720582342 :tmi.twitch.tv CLEARMSG #dallas :HeyGuys`,
+		},
+		{
+			name: "usernotice",
+			line: `@badges=staff/1,broadcaster/1;color=#008000;display-name=ronni;emotes=;id=db25007f;login=ronni;mod=0;msg-id=resub;msg-param-cumulative-months=6;room-id=1337;system-msg=ronni\shas\ssubscribed\sfor\s6\smonths!;tmi-sent-ts=1507246572675;user-id=1337 :tmi.twitch.tv USERNOTICE #dallas :Great stream -- keep it up!`,
+		},
+		{
+			name: "roomstate",
+			line: `@emote-only=0;followers-only=-1;r9k=0;room-id=1;slow=0;subs-only=0 :tmi.twitch.tv ROOMSTATE #chan`,
+		},
+		{
+			name: "notice without channel",
+			line: `:tmi.twitch.tv NOTICE * :Login authentication failed`,
+		},
+		{
+			name: "join",
+			line: `:ronni!ronni@ronni.tmi.twitch.tv JOIN #dallas`,
+		},
+		{
+			name: "whisper",
+			line: `@badges=;color=;display-name=Petsgomoo;emotes=;message-id=306;mod=0;thread-id=1_2;user-id=87654321 :petsgomoo!petsgomoo@petsgomoo.tmi.twitch.tv WHISPER foo :hello`,
+		},
+		{
+			name: "names",
+			line: `:tmi.twitch.tv 353 bot = #chan :bot viewer other`,
+		},
+		{
+			name: "ping",
+			line: `PING :tmi.twitch.tv`,
+		},
+	}
+	for _, tt := range tests {
+		want, err := Parse(tt.line)
+		if err != nil {
+			t.Fatalf("%q. Parse() error = %v", tt.name, err)
+		}
+		line, err := Serialize(want)
+		if err != nil {
+			t.Fatalf("%q. Serialize() error = %v", tt.name, err)
+		}
+		got, err := Parse(line)
+		if err != nil {
+			t.Errorf("%q. Parse(Serialize()) error = %v, line %q", tt.name, err, line)
+			continue
+		}
+		for _, m := range []interface{}{want, got} {
+			reflect.ValueOf(m).Elem().FieldByName("RawMessage").SetString("")
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q. Parse(Serialize()) = %+v, want %+v", tt.name, got, want)
+		}
+		if again, _ := Serialize(got); again != line {
+			t.Errorf("%q. Serialize() = %q, want %q", tt.name, again, line)
+		}
+		a, _ := ParseIRC(tt.line)
+		b, _ := ParseIRC(line)
+		if a.Prefix != b.Prefix || a.Command != b.Command || !reflect.DeepEqual(a.Params, b.Params) || a.Trailing != b.Trailing {
+			t.Errorf("%q. Serialize() = %q, want %q", tt.name, line, tt.line)
+		}
+	}
+}
+
+func TestIRCMessage_String(t *testing.T) {
+	m := &IRCMessage{Tags: map[string]string{"b": "x y;z", "a": ""}, Name: "u", User: "u", Host: "u.tmi.twitch.tv",
+		Command: "PRIVMSG", Params: []string{"#chan"}, Trailing: ":)"}
+	want := `@a=;b=x\sy\:z :u!u@u.tmi.twitch.tv PRIVMSG #chan ::)`
+	if got := m.String(); got != want {
+		t.Errorf("IRCMessage.String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,231 @@
+package twitch
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OAuthURL is base of twitch oauth api, can be changed for fake servers
+var OAuthURL = "https://id.twitch.tv/oauth2"
+
+// validateInterval is how often token is validated, twitch requires it every hour
+const validateInterval = time.Hour
+
+// refreshBefore is time before expiry when token is refreshed
+const refreshBefore = time.Minute
+
+// Token is oauth user access token
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	Expiry       time.Time `json:"expiry"`
+	Scopes       []string  `json:"scopes"`
+}
+
+// TokenSource gives valid token for login and helix requests
+type TokenSource interface {
+	Token() (Token, error)
+}
+
+// StaticToken is token which is never validated and refreshed
+type StaticToken string
+
+func (t StaticToken) Token() (Token, error) {
+	return Token{AccessToken: strings.TrimPrefix(string(t), "oauth:")}, nil
+}
+
+// AuthError is rejected token: failed login to chat or invalid token for api
+type AuthError struct {
+	Message string
+}
+
+func (e *AuthError) Error() string {
+	return "twitch auth: " + e.Message
+}
+
+// ScopeError is token without required scopes
+type ScopeError struct {
+	Missing []string
+}
+
+func (e *ScopeError) Error() string {
+	return "twitch auth: token has no scopes " + strings.Join(e.Missing, ", ")
+}
+
+// TokenInfo is response of /validate
+type TokenInfo struct {
+	ClientID  string   `json:"client_id"`
+	Login     string   `json:"login"`
+	UserID    string   `json:"user_id"`
+	Scopes    []string `json:"scopes"`
+	ExpiresIn int      `json:"expires_in"`
+}
+
+// Validate checks token, invalid token is *AuthError
+func Validate(accessToken string) (TokenInfo, error) {
+	var info TokenInfo
+	req, err := http.NewRequest("GET", OAuthURL+"/validate", nil)
+	if err != nil {
+		return info, err
+	}
+	req.Header.Set("Authorization", "OAuth "+strings.TrimPrefix(accessToken, "oauth:"))
+	b, err := doOAuth(req)
+	if err != nil {
+		return info, err
+	}
+	err = json.Unmarshal(b, &info)
+	return info, err
+}
+
+// RefreshToken gets new pair of access and refresh tokens, old refresh token
+// can't be used again
+func RefreshToken(clientID, clientSecret, refreshToken string) (Token, error) {
+	var t Token
+	values := url.Values{}
+	values.Set("grant_type", "refresh_token")
+	values.Set("refresh_token", refreshToken)
+	values.Set("client_id", clientID)
+	values.Set("client_secret", clientSecret)
+	req, err := http.NewRequest("POST", OAuthURL+"/token", strings.NewReader(values.Encode()))
+	if err != nil {
+		return t, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	b, err := doOAuth(req)
+	if err != nil {
+		return t, err
+	}
+	var resp struct {
+		AccessToken  string   `json:"access_token"`
+		RefreshToken string   `json:"refresh_token"`
+		ExpiresIn    int      `json:"expires_in"`
+		Scope        []string `json:"scope"`
+	}
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return t, err
+	}
+	t = Token{AccessToken: resp.AccessToken, RefreshToken: resp.RefreshToken, Scopes: resp.Scope}
+	if resp.ExpiresIn > 0 {
+		t.Expiry = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	}
+	return t, nil
+}
+
+func doOAuth(req *http.Request) ([]byte, error) {
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusBadRequest {
+		var e struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(b, &e) != nil || e.Message == "" {
+			e.Message = string(b)
+		}
+		return nil, &AuthError{Message: e.Message}
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New("twitch oauth: " + res.Status)
+	}
+	return b, nil
+}
+
+// NewRefreshingSource creates token source which validates token every hour and
+// refreshes it when it is expired or invalid. persist is called with every new token
+func NewRefreshingSource(clientID, clientSecret string, t Token, persist func(Token)) *RefreshingSource {
+	return &RefreshingSource{clientID: clientID, clientSecret: clientSecret, token: t, persist: persist}
+}
+
+type RefreshingSource struct {
+	// Scopes are required, token without them is *ScopeError
+	Scopes []string
+
+	clientID     string
+	clientSecret string
+	persist      func(Token)
+	token        Token
+	validated    time.Time
+	locker       sync.Mutex
+}
+
+// Token returns valid token, it is validated or refreshed if needed
+func (s *RefreshingSource) Token() (Token, error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if !s.token.Expiry.IsZero() && time.Now().Add(refreshBefore).After(s.token.Expiry) {
+		if err := s.refresh(); err != nil {
+			return s.token, err
+		}
+	}
+	if time.Since(s.validated) > validateInterval {
+		info, err := Validate(s.token.AccessToken)
+		if _, ok := err.(*AuthError); ok {
+			if err := s.refresh(); err != nil {
+				return s.token, err
+			}
+			info, err = Validate(s.token.AccessToken)
+		}
+		if err != nil {
+			return s.token, err
+		}
+		s.validated = time.Now()
+		s.token.Scopes = info.Scopes
+		if info.ExpiresIn > 0 {
+			s.token.Expiry = s.validated.Add(time.Duration(info.ExpiresIn) * time.Second)
+		}
+	}
+	if missing := missingScopes(s.token.Scopes, s.Scopes); len(missing) > 0 {
+		return s.token, &ScopeError{Missing: missing}
+	}
+	return s.token, nil
+}
+
+// Invalidate makes next Token refresh token, it is used after failed login
+func (s *RefreshingSource) Invalidate() {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.validated = time.Time{}
+	s.token.Expiry = time.Unix(1, 0)
+}
+
+func (s *RefreshingSource) refresh() error {
+	if s.token.RefreshToken == "" {
+		return &AuthError{Message: "token is expired and there is no refresh token"}
+	}
+	t, err := RefreshToken(s.clientID, s.clientSecret, s.token.RefreshToken)
+	if err != nil {
+		return err
+	}
+	s.token = t
+	s.validated = time.Time{}
+	if s.persist != nil {
+		s.persist(t)
+	}
+	return nil
+}
+
+func missingScopes(have, need []string) []string {
+	set := map[string]bool{}
+	for _, s := range have {
+		set[s] = true
+	}
+	var missing []string
+	for _, s := range need {
+		if !set[s] {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,163 @@
+package twitch_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FireGM/chats/chatstest"
+	"github.com/FireGM/chats/twitch"
+)
+
+func newOAuth() (*chatstest.OAuthServer, func()) {
+	s := chatstest.NewOAuthServer()
+	url := twitch.OAuthURL
+	twitch.OAuthURL = s.URL()
+	return s, func() {
+		twitch.OAuthURL = url
+		s.Close()
+	}
+}
+
+func TestRefreshingSource(t *testing.T) {
+	s, done := newOAuth()
+	defer done()
+	s.AddToken("access", "refresh", "bot", "chat:read", "chat:edit")
+	var persisted []twitch.Token
+	ts := twitch.NewRefreshingSource("client", "secret", twitch.Token{AccessToken: "access", RefreshToken: "refresh"},
+		func(t twitch.Token) { persisted = append(persisted, t) })
+	ts.Scopes = []string{"chat:read"}
+
+	tok, err := ts.Token()
+	if err != nil || tok.AccessToken != "access" || tok.Expiry.IsZero() {
+		t.Fatalf("Token() = %+v, %v", tok, err)
+	}
+	s.Expire("access")
+	ts.Invalidate()
+	tok, err = ts.Token()
+	if err != nil || tok.AccessToken != "access+" || tok.RefreshToken != "refresh+" {
+		t.Fatalf("Token() after expire = %+v, %v", tok, err)
+	}
+	if len(persisted) != 1 || persisted[0].AccessToken != "access+" {
+		t.Errorf("persisted = %+v, want refreshed token", persisted)
+	}
+
+	ts.Scopes = []string{"moderator:manage:banned_users"}
+	ts.Invalidate()
+	_, err = ts.Token()
+	if e, ok := err.(*twitch.ScopeError); !ok || len(e.Missing) != 1 {
+		t.Errorf("Token() without scope = %v, want ScopeError", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s, done := newOAuth()
+	defer done()
+	s.AddToken("access", "", "bot", "chat:read")
+	info, err := twitch.Validate("oauth:access")
+	if err != nil || info.Login != "bot" || len(info.Scopes) != 1 {
+		t.Errorf("Validate() = %+v, %v", info, err)
+	}
+	if _, err := twitch.Validate("wrong"); err == nil {
+		t.Error("Validate(wrong) = nil, want AuthError")
+	} else if _, ok := err.(*twitch.AuthError); !ok {
+		t.Errorf("Validate(wrong) = %T, want *AuthError", err)
+	}
+}
+
+func TestBotAuth(t *testing.T) {
+	o, done := newOAuth()
+	defer done()
+	o.AddToken("old", "refresh", "bot", "chat:read")
+	s, err := chatstest.NewTwitchServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Token = "old+"
+
+	b := twitch.New("bot", "oauth:wrong", nil)
+	b.SetServer(s.Addr())
+	var authErr error
+	b.OnAuthError(func(err error, _ *twitch.Bot) { authErr = err })
+	err = b.Connect()
+	if _, ok := err.(*twitch.AuthError); !ok {
+		t.Errorf("Connect() with wrong token = %v, want AuthError", err)
+	}
+	if authErr == nil {
+		t.Error("OnAuthError is not called")
+	}
+
+	// token is valid for /validate but rejected by chat, it is refreshed
+	b = twitch.New("bot", "", nil)
+	b.SetServer(s.Addr())
+	var persisted twitch.Token
+	b.SetTokenSource(twitch.NewRefreshingSource("client", "secret",
+		twitch.Token{AccessToken: "old", RefreshToken: "refresh"}, func(t twitch.Token) { persisted = t }))
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Connect() with refreshing source = %v", err)
+	}
+	defer b.Disconnect()
+	if persisted.AccessToken != "old+" {
+		t.Errorf("persisted = %+v, want refreshed token", persisted)
+	}
+	if !s.WaitFor("PASS oauth:old+", time.Second) {
+		t.Error("refreshed token is not used for login")
+	}
+}
+
+func TestBotReconnectStopsOnFailedRefresh(t *testing.T) {
+	o, done := newOAuth()
+	defer done()
+	// refresh token is revoked, /token answers 400
+	o.AddToken("access", "", "bot", "chat:read")
+	s, err := chatstest.NewTwitchServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Token = "access"
+
+	b := twitch.New("bot", "", nil)
+	b.SetServer(s.Addr())
+	b.SetTokenSource(twitch.NewRefreshingSource("client", "secret",
+		twitch.Token{AccessToken: "access", RefreshToken: "revoked"}, nil))
+	var locker sync.Mutex
+	var errs []error
+	b.OnAuthError(func(err error, _ *twitch.Bot) {
+		locker.Lock()
+		defer locker.Unlock()
+		errs = append(errs, err)
+	})
+	list := func() []error {
+		locker.Lock()
+		defer locker.Unlock()
+		return append([]error(nil), errs...)
+	}
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+
+	s.SetToken("other")
+	s.DisconnectAll()
+	waitUntil(t, "auth errors", func() bool { return len(list()) >= 2 })
+	time.Sleep(200 * time.Millisecond)
+	got := list()
+	if len(got) != 2 {
+		t.Fatalf("auth errors = %v, want rejected login and failed refresh", got)
+	}
+	if e, ok := got[1].(*twitch.AuthError); !ok || !strings.Contains(e.Message, "Invalid refresh token") {
+		t.Errorf("second auth error = %v, want failed refresh", got[1])
+	}
+	logins := 0
+	for _, l := range s.Received() {
+		if strings.HasPrefix(l, "NICK ") {
+			logins++
+		}
+	}
+	if logins != 2 {
+		t.Errorf("logins = %v, want %v", logins, 2)
+	}
+}
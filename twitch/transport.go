@@ -0,0 +1,115 @@
+package twitch
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const serverTLSPort = "6697"
+const webSocketURL = "wss://irc-ws.chat.twitch.tv:443"
+
+// Transport dials connection to twitch irc
+type Transport interface {
+	Dial() (net.Conn, error)
+}
+
+// TCPTransport is plain irc, default is irc.chat.twitch.tv:6667
+type TCPTransport struct {
+	Addr string
+}
+
+func (t TCPTransport) Dial() (net.Conn, error) {
+	addr := t.Addr
+	if addr == "" {
+		addr = server + ":" + serverPort
+	}
+	return net.Dial("tcp", addr)
+}
+
+// TLSTransport is irc over tls, default is irc.chat.twitch.tv:6697
+type TLSTransport struct {
+	Addr   string
+	Config *tls.Config
+}
+
+func (t TLSTransport) Dial() (net.Conn, error) {
+	addr := t.Addr
+	if addr == "" {
+		addr = server + ":" + serverTLSPort
+	}
+	return tls.Dial("tcp", addr, t.Config)
+}
+
+// WebSocketTransport is irc over websocket, default is wss://irc-ws.chat.twitch.tv:443.
+// It works where only 443 port is allowed
+type WebSocketTransport struct {
+	URL    string
+	Header http.Header
+	Dialer *websocket.Dialer
+}
+
+func (t WebSocketTransport) Dial() (net.Conn, error) {
+	url := t.URL
+	if url == "" {
+		url = webSocketURL
+	}
+	dialer := t.Dialer
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+	conn, _, err := dialer.Dial(url, t.Header)
+	if err != nil {
+		return nil, err
+	}
+	return &wsConn{Conn: conn}, nil
+}
+
+// wsConn is net.Conn over websocket, every write is one text frame
+type wsConn struct {
+	*websocket.Conn
+	reader      io.Reader
+	writeLocker sync.Mutex
+}
+
+func (c *wsConn) Read(p []byte) (int, error) {
+	for {
+		if c.reader == nil {
+			_, r, err := c.NextReader()
+			if err != nil {
+				return 0, err
+			}
+			c.reader = r
+		}
+		n, err := c.reader.Read(p)
+		if err == io.EOF {
+			c.reader = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (c *wsConn) Write(p []byte) (int, error) {
+	c.writeLocker.Lock()
+	defer c.writeLocker.Unlock()
+	if err := c.WriteMessage(websocket.TextMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *wsConn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
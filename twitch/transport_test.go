@@ -0,0 +1,54 @@
+package twitch
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWebSocketTransport(t *testing.T) {
+	got := make(chan string, 1)
+	upgrader := websocket.Upgrader{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.WriteMessage(websocket.TextMessage, []byte("PING :a\r\n:tmi.twitch.tv 001 nick :Welcome\r\n"))
+		conn.WriteMessage(websocket.TextMessage, []byte("PING :b\r\n"))
+		_, data, err := conn.ReadMessage()
+		if err == nil {
+			got <- string(data)
+		}
+	}))
+	defer s.Close()
+
+	conn, err := WebSocketTransport{URL: "ws" + strings.TrimPrefix(s.URL, "http")}.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	reader := textproto.NewReader(bufio.NewReader(conn))
+	want := []string{"PING :a", ":tmi.twitch.tv 001 nick :Welcome", "PING :b"}
+	for _, w := range want {
+		line, err := reader.ReadLine()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line != w {
+			t.Errorf("ReadLine() = %q, want %q", line, w)
+		}
+	}
+	if _, err := conn.Write([]byte("PONG :b\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	if line := <-got; line != "PONG :b\r\n" {
+		t.Errorf("server got %q, want %q", line, "PONG :b\r\n")
+	}
+}
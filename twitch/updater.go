@@ -1,28 +1,28 @@
 package twitch
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 )
 
-const badgesURL = "https://badges.twitch.tv/v1/badges/global/display"
-const chanInfoURL = "https://api.twitch.tv/kraken/channels/"
-const badgesSubURLFormat = "https://badges.twitch.tv/v1/badges/channels/%d/display"
-
-//need for getting subscribe badges
+//need for getting cheermotes without helix
 var clientID = ""
 
 var client = http.Client{Timeout: time.Second * 20}
 var once = sync.Once{}
 
+// helixClient is used for badges and cheermotes, see SetHelix
+var helixClient *Helix
+var helixLocker sync.RWMutex
+
 var badges = map[string]map[string]Badge{}
-var badgesSub = map[string]map[string]Badge{}
+
+// badgesSub is subscriber badges by room id
+var badgesSub = map[int]map[string]Badge{}
+var badgesLocker sync.RWMutex
 
 type Badge struct {
 	ImageURL1x string `json:"image_url_1x"`
@@ -31,12 +31,22 @@ type Badge struct {
 	Title      string `json:"title"`
 }
 
-type BadgeSets struct {
-	Versions map[string]Badge `json:"versions"`
+// SetHelix sets client for loading of badges and cheermotes, without it badges are not rendered
+func SetHelix(h *Helix) {
+	helixLocker.Lock()
+	helixClient = h
+	helixLocker.Unlock()
+	go func() {
+		if err := requestAndParse(); err != nil {
+			log.Println(err)
+		}
+	}()
 }
 
-type BadgeResp struct {
-	BadgeSets map[string]BadgeSets `json:"badge_sets"`
+func getHelix() *Helix {
+	helixLocker.RLock()
+	defer helixLocker.RUnlock()
+	return helixClient
 }
 
 func goUpdater() {
@@ -47,105 +57,94 @@ func updater() {
 	if err != nil {
 		log.Println(err)
 	}
+	err = requestAndParseCheermotes()
+	if err != nil {
+		log.Println(err)
+	}
 	for _ = range time.Tick(time.Minute * 60) {
 		requestAndParse()
+		requestAndParseCheermotes()
 	}
 }
 
 func getBadge(name string, v string) (string, string) {
+	badgesLocker.RLock()
+	defer badgesLocker.RUnlock()
 	if badge, ok := badges[name][v]; ok {
 		return badge.ImageURL1x, badge.Title
 	}
 	return "", ""
 }
 
-func requestAndParse() error {
-	res, err := client.Get(badgesURL)
-	if err != nil {
-		return err
+func badgeVersions(sets []HelixBadgeSet) map[string]map[string]Badge {
+	versions := map[string]map[string]Badge{}
+	for _, set := range sets {
+		versions[set.SetID] = map[string]Badge{}
+		for _, v := range set.Versions {
+			versions[set.SetID][v.ID] = v.Badge
+		}
 	}
-	defer res.Body.Close()
-	b, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return err
+	return versions
+}
+
+// requestAndParse loads global badges, subscriber badges are loaded again on demand
+func requestAndParse() error {
+	h := getHelix()
+	if h == nil {
+		return nil
 	}
-	var bresp BadgeResp
-	err = json.Unmarshal(b, &bresp)
+	sets, err := h.GlobalBadges()
 	if err != nil {
 		return err
 	}
-	for k, v := range bresp.BadgeSets {
-		badges[k] = v.Versions
+	badgesLocker.Lock()
+	defer badgesLocker.Unlock()
+	for k, v := range badgeVersions(sets) {
+		badges[k] = v
 	}
+	badgesSub = map[int]map[string]Badge{}
 	return nil
 }
 
-func getBadgeSubscriber(channel, version string) (string, string) {
-	if badge, ok := badgesSub[channel][version]; ok {
-		return badge.ImageURL1x, badge.Title
+// getBadgeSubscriber returns subscriber badge of channel, global badge if channel has no own
+func getBadgeSubscriber(roomID int, version string) (string, string) {
+	badgesLocker.RLock()
+	sub, ok := badgesSub[roomID]
+	badgesLocker.RUnlock()
+	if !ok {
+		sub = requestSubBadges(roomID)
 	}
-	id, err := getChannelID(channel)
-	if err != nil {
-		log.Println(err)
-		return "", ""
-	}
-	bs, err := requestAndParseSubBadges(id)
-	if err != nil {
-		log.Println(err)
-		return "", ""
-	}
-	badgesSub[channel] = map[string]Badge{}
-	for k, v := range bs.BadgeSets["subscriber"].Versions {
-		badgesSub[channel][k] = v
+	if badge, ok := sub[version]; ok {
+		return badge.ImageURL1x, badge.Title
 	}
-	return badgesSub[channel][version].ImageURL1x, badgesSub[channel][version].Title
+	return getBadge("subscriber", version)
 }
 
-func requestAndParseSubBadges(id int) (BadgeResp, error) {
-	var bs BadgeResp
-	res, err := client.Get(fmt.Sprintf(badgesSubURLFormat, id))
-	if err != nil {
-		return bs, err
-	}
-	defer res.Body.Close()
-	b, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return bs, err
-	}
-
-	err = json.Unmarshal(b, &bs)
-	if err != nil {
-		return bs, err
-	}
-	if _, ok := bs.BadgeSets["subscriber"]; !ok {
-		return bs, errors.New("No subscribers")
+// cachedBadgeSubscriber is getBadgeSubscriber without request of channel badges
+func cachedBadgeSubscriber(roomID int, version string) (string, string) {
+	badgesLocker.RLock()
+	badge, ok := badgesSub[roomID][version]
+	badgesLocker.RUnlock()
+	if ok {
+		return badge.ImageURL1x, badge.Title
 	}
-
-	return bs, nil
+	return getBadge("subscriber", version)
 }
 
-func getChannelID(channel string) (int, error) {
-	req, _ := http.NewRequest("GET", chanInfoURL+channel, nil)
-	req.Header.Add("Client-ID", clientID)
-	res, err := client.Do(req)
-	if err != nil {
-		return 0, err
-	}
-	defer res.Body.Close()
-	b, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return 0, err
-	}
-	var m struct {
-		ID int `json:"_id"`
-	}
-	err = json.Unmarshal(b, &m)
-	if err != nil {
-		return 0, err
-	}
-	if m.ID == 0 {
-		log.Println(channel, string(b))
-		return 0, errors.New("No id")
-	}
-	return m.ID, nil
+// requestSubBadges caches result even on error, cache is cleared by updater
+func requestSubBadges(roomID int) map[string]Badge {
+	sub := map[string]Badge{}
+	if h := getHelix(); h != nil && roomID != 0 {
+		sets, err := h.ChannelBadges(strconv.Itoa(roomID))
+		if err != nil {
+			log.Println(err)
+		}
+		if v, ok := badgeVersions(sets)["subscriber"]; ok {
+			sub = v
+		}
+	}
+	badgesLocker.Lock()
+	badgesSub[roomID] = sub
+	badgesLocker.Unlock()
+	return sub
 }
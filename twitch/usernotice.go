@@ -0,0 +1,123 @@
+package twitch
+
+import (
+	"strconv"
+
+	"github.com/FireGM/chats/interfaces"
+)
+
+// UserNoticeEvent is implemented by all USERNOTICE messages
+type UserNoticeEvent interface {
+	interfaces.Message
+	Notice() *UserNotice
+}
+
+// UserNotice is USERNOTICE with common tags, msg-id without own type is delivered as is
+type UserNotice struct {
+	Message
+	MsgID     string `json:"msg_id"`
+	Login     string `json:"login"`
+	UserID    string `json:"user_id"`
+	SystemMsg string `json:"system_msg"`
+}
+
+func (n *UserNotice) Notice() *UserNotice {
+	return n
+}
+
+// IsFromUser is true when user attached text to notice, like message of resub
+func (n *UserNotice) IsFromUser() bool {
+	return n.Text != ""
+}
+
+// SubNotice is sub and resub
+type SubNotice struct {
+	UserNotice
+	CumulativeMonths  int    `json:"cumulative_months"`
+	StreakMonths      int    `json:"streak_months"`
+	ShouldShareStreak bool   `json:"should_share_streak"`
+	SubPlan           string `json:"sub_plan"`
+	SubPlanName       string `json:"sub_plan_name"`
+}
+
+// SubGiftNotice is subgift and anonsubgift
+type SubGiftNotice struct {
+	UserNotice
+	Months               int    `json:"months"`
+	GiftMonths           int    `json:"gift_months"`
+	RecipientID          string `json:"recipient_id"`
+	RecipientLogin       string `json:"recipient_login"`
+	RecipientDisplayName string `json:"recipient_display_name"`
+	SubPlan              string `json:"sub_plan"`
+	SubPlanName          string `json:"sub_plan_name"`
+}
+
+// MysteryGiftNotice is submysterygift, gifts to random users of channel
+type MysteryGiftNotice struct {
+	UserNotice
+	MassGiftCount int    `json:"mass_gift_count"`
+	SenderCount   int    `json:"sender_count"`
+	SubPlan       string `json:"sub_plan"`
+}
+
+// RaidNotice is raid of channel, FromLogin is raider
+type RaidNotice struct {
+	UserNotice
+	FromLogin       string `json:"from_login"`
+	FromDisplayName string `json:"from_display_name"`
+	ViewerCount     int    `json:"viewer_count"`
+}
+
+// AnnouncementNotice is /announce of moderator, Color is PRIMARY, BLUE, GREEN, ORANGE or PURPLE
+type AnnouncementNotice struct {
+	UserNotice
+	Color string `json:"announcement_color"`
+}
+
+func parseUserNotice(m Message) UserNoticeEvent {
+	n := UserNotice{Message: m, MsgID: m.Tags["msg-id"], Login: m.Tags["login"],
+		UserID: m.Tags["user-id"], SystemMsg: m.Tags["system-msg"]}
+	if n.User == "" {
+		n.User = n.Login
+	}
+	switch n.MsgID {
+	case "sub", "resub":
+		return &SubNotice{UserNotice: n,
+			CumulativeMonths:  tagInt(m.Tags, "msg-param-cumulative-months"),
+			StreakMonths:      tagInt(m.Tags, "msg-param-streak-months"),
+			ShouldShareStreak: m.Tags["msg-param-should-share-streak"] == "1",
+			SubPlan:           m.Tags["msg-param-sub-plan"],
+			SubPlanName:       m.Tags["msg-param-sub-plan-name"],
+		}
+	case "subgift", "anonsubgift":
+		return &SubGiftNotice{UserNotice: n,
+			Months:               tagInt(m.Tags, "msg-param-months"),
+			GiftMonths:           tagInt(m.Tags, "msg-param-gift-months"),
+			RecipientID:          m.Tags["msg-param-recipient-id"],
+			RecipientLogin:       m.Tags["msg-param-recipient-user-name"],
+			RecipientDisplayName: m.Tags["msg-param-recipient-display-name"],
+			SubPlan:              m.Tags["msg-param-sub-plan"],
+			SubPlanName:          m.Tags["msg-param-sub-plan-name"],
+		}
+	case "submysterygift", "anonsubmysterygift":
+		return &MysteryGiftNotice{UserNotice: n,
+			MassGiftCount: tagInt(m.Tags, "msg-param-mass-gift-count"),
+			SenderCount:   tagInt(m.Tags, "msg-param-sender-count"),
+			SubPlan:       m.Tags["msg-param-sub-plan"],
+		}
+	case "raid":
+		return &RaidNotice{UserNotice: n,
+			FromLogin:       m.Tags["msg-param-login"],
+			FromDisplayName: m.Tags["msg-param-displayName"],
+			ViewerCount:     tagInt(m.Tags, "msg-param-viewerCount"),
+		}
+	case "announcement":
+		return &AnnouncementNotice{UserNotice: n, Color: m.Tags["msg-param-color"]}
+	}
+	return &n
+}
+
+func tagInt(tags map[string]string, key string) int {
+	v, _ := strconv.Atoi(tags[key])
+	return v
+}
@@ -0,0 +1,80 @@
+package twitch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseUserNotice(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want UserNoticeEvent
+	}{
+		{
+			name: "resub",
+			line: `@badge-info=;badges=staff/1,broadcaster/1,turbo/1;color=#008000;display-name=ronni;emotes=;id=db25007f-7a18-43eb-9379-80131e44d633;login=ronni;mod=0;msg-id=resub;msg-param-cumulative-months=6;msg-param-streak-months=2;msg-param-should-share-streak=1;msg-param-sub-plan=Prime;msg-param-sub-plan-name=Prime;room-id=12345678;subscriber=1;system-msg=ronni\shas\ssubscribed\sfor\s6\smonths!;tmi-sent-ts=1507246572675;turbo=1;user-id=87654321;user-type=staff :tmi.twitch.tv USERNOTICE #dallas :Great stream -- keep it up!`,
+			want: &SubNotice{CumulativeMonths: 6, StreakMonths: 2, ShouldShareStreak: true, SubPlan: "Prime", SubPlanName: "Prime"},
+		},
+		{
+			name: "subgift",
+			line: `@badge-info=;badges=staff/1,premium/1;color=#0000FF;display-name=TWW2;emotes=;id=e9176cd8-5e22-4684-ad40-ce53c2561c5e;login=tww2;mod=0;msg-id=subgift;msg-param-months=1;msg-param-recipient-display-name=Mr_Woodchuck;msg-param-recipient-id=55554444;msg-param-recipient-user-name=mr_woodchuck;msg-param-sub-plan-name=House\sof\sNyoro~n;msg-param-sub-plan=1000;room-id=19571752;subscriber=0;system-msg=TWW2\sgifted\sa\sTier\s1\ssub\sto\sMr_Woodchuck!;tmi-sent-ts=1521159445153;turbo=0;user-id=87654321;user-type=staff :tmi.twitch.tv USERNOTICE #forstycup`,
+			want: &SubGiftNotice{Months: 1, RecipientID: "55554444", RecipientLogin: "mr_woodchuck", RecipientDisplayName: "Mr_Woodchuck", SubPlan: "1000", SubPlanName: "House of Nyoro~n"},
+		},
+		{
+			name: "raid",
+			line: `@badge-info=;badges=turbo/1;color=#9ACD32;display-name=TestChannel;emotes=;id=3d830f12-795c-447d-af3c-ea05e40fbddb;login=testchannel;mod=0;msg-id=raid;msg-param-displayName=TestChannel;msg-param-login=testchannel;msg-param-viewerCount=15;room-id=33332222;subscriber=0;system-msg=15\sraiders\sfrom\sTestChannel\shave\sjoined\n!;tmi-sent-ts=1507246572675;turbo=1;user-id=123456;user-type= :tmi.twitch.tv USERNOTICE #othertestchannel`,
+			want: &RaidNotice{FromLogin: "testchannel", FromDisplayName: "TestChannel", ViewerCount: 15},
+		},
+		{
+			name: "announcement",
+			line: `@badge-info=;badges=broadcaster/1;color=#033700;display-name=Streamer;emotes=;login=streamer;mod=0;msg-id=announcement;msg-param-color=PRIMARY;room-id=1;subscriber=0;system-msg=;tmi-sent-ts=1648758023469;user-id=1 :tmi.twitch.tv USERNOTICE #streamer :Hello everyone`,
+			want: &AnnouncementNotice{Color: "PRIMARY"},
+		},
+		{
+			name: "unknown msg-id",
+			line: `@login=ronni;msg-id=ritual;msg-param-ritual-name=new_chatter;system-msg=@ronni\sis\snew\shere! :tmi.twitch.tv USERNOTICE #seventoes :kappa`,
+			want: &UserNotice{},
+		},
+	}
+	for _, tt := range tests {
+		m, err := Parse(tt.line)
+		if err != nil {
+			t.Errorf("%q. Parse() error = %v", tt.name, err)
+			continue
+		}
+		got, ok := m.(UserNoticeEvent)
+		if !ok || reflect.TypeOf(got) != reflect.TypeOf(tt.want) {
+			t.Errorf("%q. Parse() = %T, want %T", tt.name, m, tt.want)
+			continue
+		}
+		// compare only own fields of variant
+		if _, ok := got.(*UserNotice); ok {
+			continue
+		}
+		gotV := reflect.ValueOf(got).Elem()
+		wantV := reflect.ValueOf(tt.want).Elem()
+		for i := 0; i < gotV.NumField(); i++ {
+			if gotV.Type().Field(i).Name == "UserNotice" || gotV.Type().Field(i).Name == "Message" {
+				continue
+			}
+			if !reflect.DeepEqual(gotV.Field(i).Interface(), wantV.Field(i).Interface()) {
+				t.Errorf("%q. %s = %v, want %v", tt.name, gotV.Type().Field(i).Name,
+					gotV.Field(i).Interface(), wantV.Field(i).Interface())
+			}
+		}
+	}
+
+	m, _ := Parse(tests[2].line)
+	n := m.(UserNoticeEvent).Notice()
+	if n.SystemMsg != "15 raiders from TestChannel have joined\n!" || n.MsgID != "raid" || n.Channel != "othertestchannel" {
+		t.Errorf("Notice() = %+v", n)
+	}
+	if n.IsFromUser() {
+		t.Error("raid without text IsFromUser() = true")
+	}
+	m, _ = Parse(tests[0].line)
+	if n := m.(UserNoticeEvent).Notice(); !n.IsFromUser() || n.GetUserFrom() != "ronni" {
+		t.Errorf("resub with text IsFromUser() = %v, GetUserFrom() = %q", n.IsFromUser(), n.GetUserFrom())
+	}
+}
@@ -26,20 +26,18 @@ type YouChannel struct { //todo: oAuth in channel?
 	sync.RWMutex
 }
 
-func (y *YouChannel) reader(handler func(interfaces.Message, interfaces.Bot), apiKey string, bot *Bot) {
+// retryDelay is pause after failed request of messages
+var retryDelay = time.Second
+
+func (y *YouChannel) reader(handler func(interfaces.Message, interfaces.Bot), base, apiKey string, bot *Bot) {
 	errorCounter := 0
-	for {
-		y.RLock()
-		if y.stop {
-			y.RUnlock()
-			break
-		}
-		messages, err := getMessages(y.ChatID, apiKey)
+	for !y.stopped() {
+		messages, err := getMessages(base, y.ChatID, apiKey)
 		if err != nil {
 			errorCounter++
 			log.Println(err)
 			if errorCounter < 10 {
-				y.RUnlock()
+				time.Sleep(retryDelay)
 				continue
 			}
 			err := bot.Leave(y.ChannelID)
@@ -50,20 +48,14 @@ func (y *YouChannel) reader(handler func(interfaces.Message, interfaces.Bot), ap
 		}
 		errorCounter = 0
 		sleeper := getSleepTime(messages.PollingIntervalMillis)
-		if messages.PageInfo.TotalResults < 1 || len(messages.Items) < 1 {
-			y.RUnlock()
-			time.Sleep(sleeper)
-			continue
-		}
 		newLast := y.LastMessage
 		for _, message := range messages.Items {
 			mes, err := parseMessage(message, y.ChannelID)
 			if err != nil {
 				log.Println(err)
-				y.RUnlock()
 				continue
 			}
-			if mes.SendTime.After(y.LastMessage) {
+			if mes.SendTime.After(y.LastMessage) && !y.stopped() {
 				handler(&mes, bot)
 				if mes.SendTime.After(newLast) {
 					newLast = mes.SendTime
@@ -71,12 +63,19 @@ func (y *YouChannel) reader(handler func(interfaces.Message, interfaces.Bot), ap
 			}
 		}
 		y.LastMessage = newLast
-		y.RUnlock()
 		time.Sleep(sleeper)
 	}
 }
 
+func (y *YouChannel) stopped() bool {
+	y.RLock()
+	defer y.RUnlock()
+	return y.stop
+}
+
 func (y *YouChannel) Stop() {
+	y.Lock()
+	defer y.Unlock()
 	y.stop = true
 }
 
@@ -86,11 +85,33 @@ type Bot struct {
 	handleFunc func(interfaces.Message, interfaces.Bot)
 	apiKey     string
 	oAuth      string
+	url        string
 	sync.RWMutex
 }
 
+// SetURL changes base url of youtube data api
+func (b *Bot) SetURL(url string) {
+	b.Lock()
+	defer b.Unlock()
+	b.url = url
+}
+
+// apiURL should be called under lock
+func (b *Bot) apiURL() string {
+	if b.url != "" {
+		return b.url
+	}
+	return apiURL
+}
+
 func (b *Bot) Disconnect() error {
-	for ch, _ := range b.streams {
+	b.RLock()
+	var channels []string
+	for ch := range b.streams {
+		channels = append(channels, ch)
+	}
+	b.RUnlock()
+	for _, ch := range channels {
 		b.Leave(ch)
 	}
 	return nil
@@ -99,22 +120,22 @@ func (b *Bot) Disconnect() error {
 func (b *Bot) Join(channelID string) error {
 	b.Lock()
 	defer b.Unlock()
-	chatID, err := GetChatIDByChannel(channelID, b.apiKey)
+	chatID, err := getChatID(b.apiURL(), channelID, b.apiKey)
 	if err != nil {
 		return err
 	}
 	ych := YouChannel{ChannelID: channelID, ChatID: chatID, LastMessage: time.Now()}
-	if _, ok := b.streams[channelID]; ok {
-		b.Leave(channelID)
+	if old, ok := b.streams[channelID]; ok {
+		old.Stop()
 	}
-	go ych.reader(b.handleFunc, b.apiKey, b)
+	go ych.reader(b.handleFunc, b.apiURL(), b.apiKey, b)
 	b.streams[channelID] = &ych
 	return nil
 }
 
 func (b *Bot) Leave(ch string) error {
-	b.RLock()
-	defer b.RUnlock()
+	b.Lock()
+	defer b.Unlock()
 	uChannel, ok := b.streams[ch]
 	if !ok {
 		return errors.New("Channel non found")
@@ -134,7 +155,7 @@ func (b *Bot) SendMessageToChan(channel, message string) error {
 	if !ok {
 		return errors.New("Need join to channel")
 	}
-	return sendMessageToChat(uChannel.ChatID, message, b.oAuth, b.apiKey)
+	return sendMessageToChat(b.apiURL(), uChannel.ChatID, message, b.oAuth, b.apiKey)
 }
 
 func (b *Bot) Ban(channel, channelId string) error {
@@ -148,18 +169,21 @@ func (b *Bot) Ban(channel, channelId string) error {
 		return errors.New("No chat join")
 	}
 	log.Printf("ban channel %s in chat %s for channel %s", channelId, ch.ChatID, channel)
-	return banUser(ch.ChatID, channelId, 72000, b.oAuth, b.apiKey)
+	return banUser(b.apiURL(), ch.ChatID, channelId, 72000, b.oAuth, b.apiKey)
 }
 
 func (b *Bot) Timeout(channel, channelId string, t int) error {
 	if err := b.checkOAuth(); err != nil {
 		return err
 	}
+	b.RLock()
 	ch, ok := b.streams[channel]
+	base := b.apiURL()
+	b.RUnlock()
 	if !ok {
 		return errors.New("No chat join")
 	}
-	return banUser(ch.ChatID, channelId, t, b.oAuth, b.apiKey)
+	return banUser(base, ch.ChatID, channelId, t, b.oAuth, b.apiKey)
 }
 
 func (b *Bot) checkOAuth() error {
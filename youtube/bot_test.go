@@ -0,0 +1,107 @@
+package youtube_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FireGM/chats/chatstest"
+	"github.com/FireGM/chats/interfaces"
+	"github.com/FireGM/chats/youtube"
+)
+
+type inbox struct {
+	messages []interfaces.Message
+	locker   sync.Mutex
+}
+
+func (i *inbox) handle(m interfaces.Message, b interfaces.Bot) {
+	i.locker.Lock()
+	defer i.locker.Unlock()
+	i.messages = append(i.messages, m)
+}
+
+func (i *inbox) list() []interfaces.Message {
+	i.locker.Lock()
+	defer i.locker.Unlock()
+	return append([]interfaces.Message(nil), i.messages...)
+}
+
+func waitUntil(t *testing.T, what string, f func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !f() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBot(t *testing.T) {
+	s := chatstest.NewYoutubeServer()
+	defer s.Close()
+	s.StartLive("chan", "video", "chat")
+	s.Message("chat", "old", "Old", "before join")
+
+	var in inbox
+	b := youtube.NewWithAuth(in.handle, "key", "token")
+	b.SetURL(s.URL())
+	if err := b.Join("missing"); err == nil {
+		t.Errorf("Join(%q) = nil, want error", "missing")
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := b.Join("chan"); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+	s.Message("chat", "viewer", "Viewer", "hello")
+	if err := b.SendMessageToChan("chan", "from bot"); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "messages", func() bool { return len(in.list()) == 2 })
+	// next polls return same items, they must not be handled again
+	time.Sleep(300 * time.Millisecond)
+	got := in.list()
+	if len(got) != 2 {
+		t.Fatalf("messages = %v, want %v", len(got), 2)
+	}
+	for i, want := range []struct{ user, text string }{{"Viewer", "hello"}, {"bot", "from bot"}} {
+		if got[i].GetUserFrom() != want.user || got[i].GetTextMessage() != want.text ||
+			got[i].GetChannelName() != "chan" {
+			t.Errorf("message %v = %v: %v, want %v: %v", i, got[i].GetUserFrom(), got[i].GetTextMessage(),
+				want.user, want.text)
+		}
+	}
+}
+
+func TestBotErrors(t *testing.T) {
+	s := chatstest.NewYoutubeServer()
+	defer s.Close()
+	s.StartLive("other", "video2", "chat2")
+
+	var in inbox
+	b := youtube.New(in.handle, "key")
+	b.SetURL(s.URL())
+	if err := b.Join("other"); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+	if err := b.SendMessageToChan("other", "hi"); err == nil {
+		t.Errorf("SendMessageToChan() without token = nil, want error")
+	}
+
+	// reader keeps polling after failed and broken responses
+	s.Fail(2, 500)
+	s.Malformed(1)
+	s.Message("chat2", "viewer", "Viewer", "after errors")
+	waitUntil(t, "message", func() bool { return len(in.list()) == 1 })
+	if got := in.list()[0].GetTextMessage(); got != "after errors" {
+		t.Errorf("GetTextMessage() = %v, want %v", got, "after errors")
+	}
+	if err := b.Leave("other"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Leave("other"); err == nil {
+		t.Errorf("Leave() twice = nil, want error")
+	}
+}
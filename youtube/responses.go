@@ -57,7 +57,7 @@ type MessageResp struct {
 }
 
 type MessagesResp struct {
-	PollingIntervalMillis int           `json:"pollingIntervalsMillis`
+	PollingIntervalMillis int           `json:"pollingIntervalMillis"`
 	PageInfo              PageInfo      `json:"pageInfo"`
 	Items                 []MessageResp `json:"items"`
 }
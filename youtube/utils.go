@@ -11,23 +11,30 @@ import (
 	"time"
 )
 
-const channelUrl = `https://www.googleapis.com/youtube/v3/search`
-const streamInfoUrl = `https://www.googleapis.com/youtube/v3/videos`
-const messagesUrlFormat = `https://www.googleapis.com/youtube/v3/liveChat/messages?liveChatId=%s&part=id,snippet,authorDetails&key=%s`
-const messagesInsertUrl = `https://www.googleapis.com/youtube/v3/liveChat/messages`
-const banUrl = `https://www.googleapis.com/youtube/v3/liveChat/bans`
+const apiURL = `https://www.googleapis.com/youtube/v3`
+
+const channelUrl = `/search`
+const streamInfoUrl = `/videos`
+const messagesUrlFormat = `/liveChat/messages?liveChatId=%s&part=id,snippet,authorDetails&key=%s`
+const messagesInsertUrl = `/liveChat/messages`
+const banUrl = `/liveChat/bans`
 
 var client = &http.Client{Timeout: time.Second * 10}
 
 func GetChatIDByChannel(channelId string, apiKey string) (string, error) {
-	channelResp, err := getChannelResp(channelId, apiKey)
+	return getChatID(apiURL, channelId, apiKey)
+}
+
+// getChatID finds live chat of channel using api at base url
+func getChatID(base, channelId, apiKey string) (string, error) {
+	channelResp, err := getChannelResp(base, channelId, apiKey)
 	if err != nil {
 		return "", err
 	}
 	if channelResp.PageInfo.TotalResults < 1 || len(channelResp.Items) < 1 {
 		return "", errors.New("No stream live. Please, use stream with live stream. It's need only one time")
 	}
-	streamResp, err := getStreamResp(channelResp.Items[0].ID.VideoID, apiKey)
+	streamResp, err := getStreamResp(base, channelResp.Items[0].ID.VideoID, apiKey)
 	if err != nil {
 		return "", err
 	}
@@ -38,7 +45,7 @@ func GetChatIDByChannel(channelId string, apiKey string) (string, error) {
 	return streamResp.Items[0].LiveStreamingDetails.ActiveLiveChatID, nil
 }
 
-func getChannelResp(channelId string, apiKey string) (ChanResp, error) {
+func getChannelResp(base, channelId string, apiKey string) (ChanResp, error) {
 	var chanResp ChanResp
 	values := url.Values{}
 	values.Set("part", "snippet")
@@ -46,7 +53,7 @@ func getChannelResp(channelId string, apiKey string) (ChanResp, error) {
 	values.Set("type", "video")
 	values.Set("eventType", "live")
 	values.Set("key", apiKey)
-	req, err := http.NewRequest("GET", channelUrl, nil)
+	req, err := http.NewRequest("GET", base+channelUrl, nil)
 	req.URL.RawQuery = values.Encode()
 	if err != nil {
 		return chanResp, err
@@ -67,9 +74,9 @@ func getChannelResp(channelId string, apiKey string) (ChanResp, error) {
 	return chanResp, nil
 }
 
-func banUser(chatId, banChannelId string, duration int, token, apiKey string) error {
+func banUser(base, chatId, banChannelId string, duration int, token, apiKey string) error {
 	body := fmt.Sprintf(`{"snippet": {"banDurationSeconds": "%d", "liveChatId": "%s", "type": "temporary", "bannedUserDetails": {"channelId": "%s"}}}`, duration, chatId, banChannelId)
-	req, err := http.NewRequest("POST", banUrl, bytes.NewBufferString(body))
+	req, err := http.NewRequest("POST", base+banUrl, bytes.NewBufferString(body))
 	if err != nil {
 		return err
 	}
@@ -95,13 +102,13 @@ func banUser(chatId, banChannelId string, duration int, token, apiKey string) er
 	return nil
 }
 
-func getStreamResp(streamId string, apiKey string) (StreamResp, error) {
+func getStreamResp(base, streamId string, apiKey string) (StreamResp, error) {
 	var streamResp StreamResp
 	values := url.Values{}
 	values.Set("id", streamId)
 	values.Set("part", "liveStreamingDetails")
 	values.Set("key", apiKey)
-	req, err := http.NewRequest("GET", streamInfoUrl, nil)
+	req, err := http.NewRequest("GET", base+streamInfoUrl, nil)
 	if err != nil {
 		return streamResp, err
 	}
@@ -122,9 +129,9 @@ func getStreamResp(streamId string, apiKey string) (StreamResp, error) {
 	return streamResp, nil
 }
 
-func getMessages(chatID string, apiKey string) (MessagesResp, error) {
+func getMessages(base, chatID string, apiKey string) (MessagesResp, error) {
 	var mr MessagesResp
-	req, err := http.NewRequest("GET", fmt.Sprintf(messagesUrlFormat, chatID, apiKey), nil)
+	req, err := http.NewRequest("GET", base+fmt.Sprintf(messagesUrlFormat, chatID, apiKey), nil)
 	if err != nil {
 		return mr, err
 	}
@@ -137,6 +144,9 @@ func getMessages(chatID string, apiKey string) (MessagesResp, error) {
 	if err != nil {
 		return mr, err
 	}
+	if res.StatusCode != 200 {
+		return mr, errors.New(string(b))
+	}
 	err = json.Unmarshal(b, &mr)
 	if err != nil {
 		return mr, err
@@ -144,18 +154,22 @@ func getMessages(chatID string, apiKey string) (MessagesResp, error) {
 	return mr, nil
 }
 
+// getSleepTime follows pollingIntervalMillis, but waits no more than 10 seconds
 func getSleepTime(t int) time.Duration {
 	tt := time.Millisecond
 	if t >= 10000 {
 		return tt * time.Duration(10000)
 	}
-	return tt * time.Duration(3000)
+	if t <= 0 {
+		return tt * time.Duration(3000)
+	}
+	return tt * time.Duration(t)
 }
 
-func sendMessageToChat(chatId, message, token, apiKey string) error {
+func sendMessageToChat(base, chatId, message, token, apiKey string) error {
 	body := fmt.Sprintf(`{"snippet": {"liveChatId": "%s", "type": "textMessageEvent", "textMessageDetails": {"messageText": "%s"}}}`,
 		chatId, message)
-	req, err := http.NewRequest("POST", messagesInsertUrl, bytes.NewBufferString(body))
+	req, err := http.NewRequest("POST", base+messagesInsertUrl, bytes.NewBufferString(body))
 	if err != nil {
 		return err
 	}